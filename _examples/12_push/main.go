@@ -26,7 +26,7 @@ func pushHandler(w http.ResponseWriter, r *http.Request) {
 	// parent request.
 	target := "/main.js"
 
-	if pusher, ok := w.(*muxie.Writer).ResponseWriter.(http.Pusher); ok {
+	if pusher, ok := w.(http.Pusher); ok {
 		err := pusher.Push(target, nil)
 		if err != nil {
 			if err == http.ErrNotSupported {
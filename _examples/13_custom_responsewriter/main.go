@@ -26,11 +26,9 @@ func profileHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 type responseWriterWithTimer struct {
-	// muxie.ParamStore
-	// http.ResponseWriter
-	// OR
-	*muxie.Writer
-	// OR/and implement the ParamStore interface by your own if you want
+	http.ResponseWriter
+	// The muxie.GetParam walks the Unwrap method to find the parameters,
+	// alternatively embed a muxie.ParamStore or implement the ParamStore interface by your own if you want
 	// to customize the way the parameters are stored and retrieved.
 	isHeaderWritten bool
 	start           time.Time
@@ -40,11 +38,16 @@ type responseWriterWithTimer struct {
 // Look at: https://github.com/kataras/muxie/issues/10 too.
 func RequestTime(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// w.(muxie.ParamStore), w OR cast it directly to the *muxie.Writer:
-		next.ServeHTTP(&responseWriterWithTimer{w.(*muxie.Writer), false, time.Now()}, r)
+		next.ServeHTTP(&responseWriterWithTimer{w, false, time.Now()}, r)
 	})
 }
 
+// Unwrap returns the original response writer,
+// it is used by the muxie.GetParam and the http.NewResponseController.
+func (w *responseWriterWithTimer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func (w *responseWriterWithTimer) WriteHeader(statusCode int) {
 	elapsed := time.Since(w.start)
 	w.Header().Set("X-Response-Time", strconv.FormatInt(elapsed.Nanoseconds(), 10))
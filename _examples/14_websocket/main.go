@@ -31,7 +31,7 @@ func main() {
 	mux := muxie.NewMux() // <-
 	mux.HandleFunc("/", serveHome)
 	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		serveWs(hub /* - > */, w.(*muxie.Writer).ResponseWriter /* <- */, r)
+		serveWs(hub, w, r) // w implements the http.Hijacker when the server's response writer does.
 	})
 
 	log.Printf("Open http://localhost%s/ in your browser.\n", *addr)
@@ -2,6 +2,7 @@ package muxie
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -48,8 +49,16 @@ var NoContentHandler http.Handler = http.HandlerFunc(func(w http.ResponseWriter,
 type MethodHandler struct {
 	// origin *Mux
 
+	// DisableHeadFallback disables the handling of the HEAD requests by the GET handler,
+	// when no HEAD handler is registered.
+	// Defaults to false, the GET handler is responsible for HEAD requests with its body discarded.
+	DisableHeadFallback bool
+
 	handlers          map[string]http.Handler // method:handler
+	methods           []string                // the registered methods, by registration order.
 	methodsAllowedStr string
+	// fallback, if not nil, is responsible for the not registered methods instead of the 405 error.
+	fallback http.Handler
 }
 
 // Handle adds a handler to be responsible for a specific HTTP Method.
@@ -72,15 +81,7 @@ func (m *MethodHandler) Handle(method string, handler http.Handler) *MethodHandl
 		return m
 	}
 
-	method = normalizeMethod(method)
-
-	if m.methodsAllowedStr == "" {
-		m.methodsAllowedStr = method
-	} else {
-		m.methodsAllowedStr += ", " + method
-	}
-
-	m.handlers[method] = handler
+	m.addMethod(normalizeMethod(method), handler)
 
 	return m
 }
@@ -91,12 +92,21 @@ func (m *MethodHandler) Handle(method string, handler http.Handler) *MethodHandl
 // Example: _examples/11_cors for more.
 func (m *MethodHandler) NoContent(methods ...string) *MethodHandler {
 	for _, method := range methods {
-		m.handlers[normalizeMethod(method)] = NoContentHandler
+		m.addMethod(normalizeMethod(method), NoContentHandler)
 	}
 
 	return m
 }
 
+func (m *MethodHandler) addMethod(method string, handler http.Handler) {
+	if _, exists := m.handlers[method]; !exists {
+		m.methods = append(m.methods, method)
+		m.methodsAllowedStr = strings.Join(m.methods, ", ")
+	}
+
+	m.handlers[method] = handler
+}
+
 // HandleFunc adds a handler function to be responsible for a specific HTTP Method.
 // Returns this MethodHandler for further calls.
 func (m *MethodHandler) HandleFunc(method string, handlerFunc func(w http.ResponseWriter, r *http.Request)) *MethodHandler {
@@ -105,19 +115,166 @@ func (m *MethodHandler) HandleFunc(method string, handlerFunc func(w http.Respon
 }
 
 func (m *MethodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.serve(w, r, m.fallback)
+}
+
+// serve serves the request by the handler of its method,
+// the "fallback", if not nil, is responsible for the not registered methods instead of the 405 error.
+func (m *MethodHandler) serve(w http.ResponseWriter, r *http.Request, fallback http.Handler) {
 	if handler, ok := m.handlers[r.Method]; ok {
 		handler.ServeHTTP(w, r)
 		return
 	}
 
+	if r.Method == http.MethodHead && m.headFallback() {
+		serveHead(m.handlers[http.MethodGet], w, r)
+		return
+	}
+
+	if fallback != nil {
+		fallback.ServeHTTP(w, r)
+		return
+	}
+
 	// RCF rfc2616 https://www.w3.org/Protocols/rfc2616/rfc2616-sec10.html
 	// The response MUST include an Allow header containing a list of valid methods for the requested resource.
 	//
 	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/Allow#Examples
-	w.Header().Set("Allow", m.methodsAllowedStr)
+	w.Header().Set("Allow", m.allowed(false))
 	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 }
 
+// methodFallbackHandler serves a `MethodHandler` registered through a `Mux`
+// with the Mux's `MethodNotAllowed` handler as its fallback.
+type methodFallbackHandler struct {
+	*MethodHandler
+	fallback http.Handler
+}
+
+func (h *methodFallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.MethodHandler.serve(w, r, h.fallback)
+}
+
+// clone returns a copy of the MethodHandler with the same handlers.
+func (m *MethodHandler) clone() *MethodHandler {
+	c := *m
+	c.handlers = make(map[string]http.Handler, len(m.handlers))
+	for method, handler := range m.handlers {
+		c.handlers[method] = handler
+	}
+	c.methods = append([]string(nil), m.methods...)
+
+	return &c
+}
+
+// hasAny reports whether a handler is registered for any of the comma or space separated "methods".
+func (m *MethodHandler) hasAny(methods string) bool {
+	for _, method := range strings.FieldsFunc(methods, func(c rune) bool {
+		return c == ',' || c == ' '
+	}) {
+		if _, exists := m.handlers[normalizeMethod(method)]; exists {
+			return true
+		}
+	}
+
+	return false
+}
+
+// headFallback reports whether the HEAD requests are served by the GET handler.
+func (m *MethodHandler) headFallback() bool {
+	if m.DisableHeadFallback {
+		return false
+	}
+
+	_, hasGet := m.handlers[http.MethodGet]
+	_, hasHead := m.handlers[http.MethodHead]
+	return hasGet && !hasHead
+}
+
+// allowed returns the value of the "Allow" header,
+// if "withOptions" is true then the OPTIONS method is included even if it's not registered.
+func (m *MethodHandler) allowed(withOptions bool) string {
+	allowed := m.methodsAllowedStr
+	if m.headFallback() {
+		allowed = appendMethod(allowed, http.MethodHead)
+	}
+
+	if _, exists := m.handlers[http.MethodOptions]; withOptions && !exists {
+		allowed = appendMethod(allowed, http.MethodOptions)
+	}
+
+	return allowed
+}
+
+func appendMethod(methods, method string) string {
+	if methods == "" {
+		return method
+	}
+
+	return methods + ", " + method
+}
+
+// serveHead serves a HEAD request through the (GET) "handler"
+// with a response writer that discards the body but sends its "Content-Length".
+func serveHead(handler http.Handler, w http.ResponseWriter, r *http.Request) {
+	hw := &headResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	handler.ServeHTTP(hw, r)
+	hw.flush()
+}
+
+// headResponseWriter discards the response body and counts its length,
+// the status code is sent at the end with the "Content-Length" header of the body, if not set manually.
+type headResponseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	written     int64
+}
+
+var _ ParamStore = (*headResponseWriter)(nil)
+
+func (w *headResponseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.statusCode = statusCode
+		w.wroteHeader = true
+	}
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	w.written += int64(len(b))
+	return len(b), nil
+}
+
+// Unwrap returns the underlying response writer.
+func (w *headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *headResponseWriter) flush() {
+	h := w.Header()
+	if h.Get("Content-Length") == "" && h.Get("Transfer-Encoding") == "" && w.written > 0 {
+		h.Set("Content-Length", strconv.FormatInt(w.written, 10))
+	}
+
+	w.ResponseWriter.WriteHeader(w.statusCode)
+}
+
+// Set implements the `ParamStore` so the path parameters are available to the GET handler.
+func (w *headResponseWriter) Set(key, value string) {
+	SetParam(w.ResponseWriter, key, value)
+}
+
+// Get implements the `ParamStore` so the path parameters are available to the GET handler.
+func (w *headResponseWriter) Get(key string) string {
+	return GetParam(w.ResponseWriter, key)
+}
+
+// GetAll implements the `ParamStore` so the path parameters are available to the GET handler.
+func (w *headResponseWriter) GetAll() []ParamEntry {
+	return GetParams(w.ResponseWriter)
+}
+
 func normalizeMethod(method string) string {
 	return strings.ToUpper(strings.TrimSpace(method))
 }
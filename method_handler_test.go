@@ -1,6 +1,7 @@
 package muxie
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -47,5 +48,142 @@ func TestMethodHandler(t *testing.T) {
 	expect(t, http.MethodDelete, srv.URL+"/user/42").statusCode(http.StatusOK).
 		bodyEq("DELETE: remove user with ID: 42\n")
 	expect(t, http.MethodPut, srv.URL+"/user/42").statusCode(http.StatusMethodNotAllowed).
-		bodyEq("Method Not Allowed\n").headerEq("Allow", "GET, POST, DELETE")
+		bodyEq("Method Not Allowed\n").headerEq("Allow", "GET, POST, DELETE, HEAD")
+}
+
+func TestMuxMethodRoutes(t *testing.T) {
+	mux := NewMux()
+	mux.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Middleware", "true")
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	mux.HandleFunc("GET /users/:id", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "GET: User details by user ID: %s", GetParam(w, "id"))
+	}, WithTag("user"))
+
+	v1 := mux.Of("/v1")
+	v1.HandleFunc("POST, PUT /users/:id", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s: save user with ID: %s", r.Method, GetParam(w, "id"))
+	})
+	v1.HandleFunc("OPTIONS /users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("custom options"))
+	})
+	mux.HandleFunc("DELETE /users/:id", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "DELETE: remove user with ID: %s", GetParam(w, "id"))
+	})
+
+	testHandler(t, mux, http.MethodGet, "/users/42").statusCode(http.StatusOK).
+		bodyEq("GET: User details by user ID: 42")
+	testHandler(t, mux, http.MethodDelete, "/users/42").statusCode(http.StatusOK).
+		bodyEq("DELETE: remove user with ID: 42")
+	testHandler(t, mux, http.MethodPost, "/users/42").statusCode(http.StatusMethodNotAllowed).
+		headerEq("Allow", "GET, DELETE, HEAD, OPTIONS").headerEq("X-Middleware", "true").
+		bodyEq("Method Not Allowed\n")
+	testHandler(t, mux, http.MethodOptions, "/users/42").statusCode(http.StatusNoContent).
+		headerEq("Allow", "GET, DELETE, HEAD, OPTIONS").headerEq("X-Middleware", "true")
+
+	testHandler(t, mux, http.MethodPut, "/v1/users/42").statusCode(http.StatusOK).
+		bodyEq("PUT: save user with ID: 42")
+	testHandler(t, mux, http.MethodGet, "/v1/users/42").statusCode(http.StatusMethodNotAllowed).
+		headerEq("Allow", "POST, PUT, OPTIONS")
+	testHandler(t, mux, http.MethodOptions, "/v1/users/42").statusCode(http.StatusOK).
+		bodyEq("custom options")
+
+	if path, err := mux.URL("user", "id", "42"); err != nil || path != "/users/42" {
+		t.Fatalf("expected the tag of the first method to be kept but got: '%s', %v", path, err)
+	}
+
+	if err := mux.Register("GET /users/:id", http.NotFoundHandler()); !errors.Is(err, ErrRouteExists) {
+		t.Fatalf("expected route exists error but got: %v", err)
+	}
+
+	if err := mux.Register("PATCH /users/:name", http.NotFoundHandler()); !errors.Is(err, ErrParamNameConflict) {
+		t.Fatalf("expected param name conflict error but got: %v", err)
+	}
+
+	if err := mux.Register("PATCH /users/:id", http.NotFoundHandler()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mux.Register("GET /v1", http.NotFoundHandler()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mux.Register("/v1", http.NotFoundHandler()); !errors.Is(err, ErrRouteExists) {
+		t.Fatalf("expected route exists error but got: %v", err)
+	}
+}
+
+func TestMethodHandlerHeadFallback(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc("GET /users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-User", GetParam(w, "id"))
+		fmt.Fprintf(w, "User details by user ID: %s", GetParam(w, "id"))
+	})
+	mux.Handle("/methods", Methods().HandleFunc(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("accepted"))
+	}))
+
+	mux.DisableHeadFallback = true
+	mux.HandleFunc("GET /no-head", func(w http.ResponseWriter, r *http.Request) {})
+
+	noHead := Methods()
+	noHead.DisableHeadFallback = true
+	mux.Handle("/methods-no-head", noHead.HandleFunc(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {}))
+
+	testHandler(t, mux, http.MethodHead, "/users/42").statusCode(http.StatusOK).
+		headerEq("X-User", "42").headerEq("Content-Length", "27").bodyEq("")
+	testHandler(t, mux, http.MethodHead, "/methods").statusCode(http.StatusAccepted).
+		headerEq("Content-Length", "8").bodyEq("")
+	testHandler(t, mux, http.MethodHead, "/no-head").statusCode(http.StatusMethodNotAllowed).
+		headerEq("Allow", "GET, OPTIONS")
+	testHandler(t, mux, http.MethodHead, "/methods-no-head").statusCode(http.StatusMethodNotAllowed).
+		headerEq("Allow", "GET")
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp := expect(t, http.MethodHead, srv.URL+"/users/42").statusCode(http.StatusOK).headerEq("X-User", "42")
+	if expected, got := int64(27), resp.resp.ContentLength; expected != got {
+		t.Fatalf("expected content length: %d but got: %d", expected, got)
+	}
+}
+
+func TestMethodHandlerShared(t *testing.T) {
+	mh := Methods().HandleFunc(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("get"))
+	})
+
+	newMux := func(body string) *Mux {
+		mux := NewMux()
+		mux.MethodNotAllowed(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte(body))
+		}))
+		return mux
+	}
+
+	first, second := newMux("first"), newMux("second")
+	first.Handle("/users", mh)
+	second.Handle("/users", mh)
+	second.Of("/v1").Handle("/users", mh)
+
+	testHandler(t, first, http.MethodPost, "/users").statusCode(http.StatusMethodNotAllowed).
+		headerEq("Allow", "GET, HEAD").bodyEq("first")
+	testHandler(t, second, http.MethodPost, "/users").statusCode(http.StatusMethodNotAllowed).
+		headerEq("Allow", "GET, HEAD").bodyEq("second")
+	testHandler(t, second, http.MethodPost, "/v1/users").statusCode(http.StatusMethodNotAllowed).
+		bodyEq("second")
+	testHandler(t, second, http.MethodGet, "/v1/users").statusCode(http.StatusOK).bodyEq("get")
+
+	// the MethodHandler itself is not modified.
+	w := httptest.NewRecorder()
+	mh.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Body.String() != "Method Not Allowed\n" {
+		t.Fatalf("expected the default 405 response but got: %d %s", w.Code, w.Body.String())
+	}
 }
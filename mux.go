@@ -1,9 +1,15 @@
 package muxie
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"path"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // Mux is an HTTP request multiplexer.
@@ -14,6 +20,12 @@ import (
 // Patterns name fixed, rooted paths and dynamic like /profile/:name
 // or /profile/:name/friends or even /files/*file when ":name" and "*file"
 // are the named parameters and wildcard parameters respectfully.
+// Named parameters can be constrained, i.e /users/:id|int or /files/:name|regex(^[a-z]+\.png$),
+// see `RegisterParamConstraint` for custom constraints.
+// Patterns can be prefixed by HTTP method(s), i.e "GET /profile/:name", see `Handle`.
+// The net/http ServeMux pattern syntax is accepted as well, i.e "GET /profile/{name}" or "/files/{file...}",
+// and the parameters are available through the `http.Request.PathValue` too, see `PathValues`.
+// A trailing slash is part of the pattern, the "/docs" and "/docs/" are different routes, see `RedirectTrailingSlash`.
 //
 // Note that since a pattern ending in a slash names a rooted subtree,
 // the pattern "/*myparam" matches all paths not matched by other registered
@@ -21,35 +33,200 @@ import (
 //
 // See `NewMux`.
 type Mux struct {
-	// PathCorrection removes leading slashes from the request path.
+	// PathCorrection removes leading slashes from the request path,
+	// the trailing slash is kept when a route is registered with it, i.e "/docs/",
+	// even if a wildcard, i.e "/*path", could catch the path with the trailing slash.
 	// Defaults to false, however is highly recommended to turn it on.
 	PathCorrection bool
 	// PathCorrectionNoRedirect if `PathCorrection` is set to true,
 	// it will execute the handlers chain without redirection.
 	// Defaults to false.
 	PathCorrectionNoRedirect bool
-	Routes                   *Trie
+	// PathClean cleans the request path before `PathCorrection`, i.e "//users///42" to "/users/42"
+	// and "/a/../admin" to "/admin", based on the `path.Clean` rules, its trailing slash is kept.
+	// Defaults to false, however is highly recommended to turn it on.
+	PathClean bool
+	// PathCleanNoRedirect if `PathClean` is set to true,
+	// it will execute the handlers chain with the cleaned path without redirection.
+	// Defaults to false.
+	PathCleanNoRedirect bool
+	// RedirectTrailingSlash redirects the client to the path with or without the trailing slash,
+	// i.e "/docs" to "/docs/", when the request path does not match a route but its other form does.
+	// Note that the "/docs" and "/docs/" are different routes.
+	// Defaults to false.
+	RedirectTrailingSlash bool
+	// DisableHeadFallback disables the handling of the HEAD requests by the GET handler
+	// of the routes registered by method(s), i.e "GET /users", see `MethodHandler.DisableHeadFallback`.
+	// Should be set before `Handle/HandleFunc`.
+	// Defaults to false.
+	DisableHeadFallback bool
+	// StrictRoutes makes `Handle/HandleFunc` to keep an already registered route instead of overriding it
+	// and to collect a `*RouteError` which describes the conflict, see `Register` too.
+	// The `Build` is required to report the conflicts at startup, it panics with all of them joined, see `errors.Join`.
+	// A Mux with conflicts answers every request with 500 Internal Server Error, even if `Build` is not called.
+	// Defaults to false.
+	StrictRoutes bool
+	// UseRawPath makes the routes to be matched against the escaped form of the request path,
+	// the `url.URL#EscapedPath`, so an encoded slash, i.e "/files/a%2Fb", does not split the path segment
+	// and the ":name" parameter receives the "a%2Fb" value.
+	// Static path segments are matched in their escaped form as well.
+	// Defaults to false.
+	UseRawPath bool
+	// UnescapePathValues unescapes the parameter values when `UseRawPath` is true,
+	// i.e the ":name" parameter of the "/files/a%2Fb" receives the "a/b" value.
+	// A value which is not a valid escaped string is kept as it is.
+	// Defaults to false.
+	UnescapePathValues bool
+	// PathValues sets the path parameters of the matched route to the request,
+	// so they are available through the `http.Request.PathValue` as well.
+	// Defaults to false, the `GetParam` is the fastest way to read them.
+	PathValues bool
+	// ContextParams stores a copy of the path parameters of the matched route to the request's context,
+	// so they are available through the `ParamsFromContext` and the `Path` binder as well.
+	// Defaults to false, the `GetParam` is the fastest way to read them.
+	ContextParams bool
+	// CaseInsensitive makes the static path segments of the routes to match regardless of their case,
+	// i.e the "/About/Kataras" request path is served by the "/about/:name" route,
+	// the parameter values keep their original case. A static path segment is preferred over
+	// a named parameter or a wildcard, i.e the "/ABOUT" is served by the "/about" and not by the "/*path". See `Trie#SearchFold`.
+	// Defaults to false.
+	CaseInsensitive bool
+	// CaseInsensitiveRedirect if `CaseInsensitive` is set to true,
+	// it redirects the client to the path as it is registered, i.e "/About/Kataras" to "/about/Kataras",
+	// instead of executing the handlers chain.
+	// Defaults to false.
+	CaseInsensitiveRedirect bool
+	// LazyMiddleware composes the `Use` middlewares with the handler of a route on its first request
+	// (or on `Build`) instead of when the route is registered,
+	// so the middlewares of a `Use` call after the `Handle/HandleFunc` apply to that route as well.
+	// Should be set before `Handle/HandleFunc` and it is inherited by the SubMuxes.
+	// Defaults to false.
+	LazyMiddleware bool
+	// Routes is the Trie which the Mux is created with, the routes registered before the Mux serves are inserted to it.
+	// A serving Mux reads its routes through an atomic pointer and registers the next routes to a copy of them,
+	// use the `Swap` to replace them and the `CurrentRoutes` to read them, instead of this field.
+	Routes *Trie
 
+	live       *liveRoutes // shared between the Mux and its SubMuxes, see `Swap`.
 	paramsPool *sync.Pool
 
+	// shared between the Mux and its SubMuxes, the handlers are registered by their Mux prefix,
+	// see `NotFound` and `MethodNotAllowed`.
+	notFoundHandlers         *Trie
+	methodNotAllowedHandlers *Trie
+
 	// per mux
 	root            string
 	requestHandlers []RequestHandler
 	beginHandlers   []Wrapper
+	routeWrappers   []Wrapper // see `With`.
+	registered      []string  // the routes registered through this Mux since the last `Use` call.
+	build           *muxBuild // shared between the Mux and its SubMuxes, see `Build`.
+}
+
+// muxBuild holds the state of the `Mux#Build`.
+type muxBuild struct {
+	lazyHandlers []*lazyHandler
+	// the routes that the middlewares of a late `Use` call were not applied to, see `Mux#LazyMiddleware`.
+	lateRoutes []string
+	// the conflicts of the `Mux#StrictRoutes` registrations, see `Mux#Build`.
+	routeErrors []error
+	// hasRouteErrors is read by the `Mux#ServeHTTP` on each request.
+	hasRouteErrors atomic.Bool
+}
+
+// liveRoutes holds the routes that a Mux serves, see `Mux#Swap` and `Mux#updateRoutes`.
+type liveRoutes struct {
+	routes atomic.Value // *Trie
+	// mu serializes the registrations and the swaps.
+	mu sync.Mutex
+	// shared reports whether the routes may be read concurrently, by a request or through the `Mux#CurrentRoutes`,
+	// since then the registrations modify a copy of them.
+	shared atomic.Bool
+}
+
+func (l *liveRoutes) load() *Trie {
+	return l.routes.Load().(*Trie)
 }
 
 // NewMux returns a new HTTP multiplexer which uses a fast, if not the fastest
 // implementation of the trie data structure that is designed especially for path segments.
 func NewMux() *Mux {
-	return &Mux{
+	m := &Mux{
 		Routes: NewTrie(),
+		live:   new(liveRoutes),
 		paramsPool: &sync.Pool{
 			New: func() interface{} {
 				return &Writer{}
 			},
 		},
-		root: "",
+		notFoundHandlers:         NewTrie(),
+		methodNotAllowedHandlers: NewTrie(),
+		root:                     "",
+		build:                    new(muxBuild),
+	}
+	m.live.routes.Store(m.Routes)
+
+	return m
+}
+
+// Swap replaces the routes that this Mux serves with the "routes" atomically
+// and returns the previous ones. Requests served at the same time
+// are handled either by the previous or by the new routes, never by a half-modified Trie.
+//
+// The Trie of a serving Mux should not be modified in place,
+// modify a copy of it and swap them instead (copy-on-write), i.e:
+// routes := mux.CurrentRoutes().Clone()
+// routes.Delete("/old")
+// mux.Swap(routes)
+//
+// The `Handle/HandleFunc`, `Register` and `Mount` of the Mux and its SubMuxes can be called while serving as well,
+// they register their routes to a copy of the served routes and swap them the same way,
+// with the `Use` and `With` middlewares and the method dispatch of the Mux.
+// Note that the `Routes` field is not modified.
+func (m *Mux) Swap(routes *Trie) *Trie {
+	if routes == nil {
+		panic("muxie/Mux#Swap: nil routes")
+	}
+
+	m.live.mu.Lock()
+	defer m.live.mu.Unlock()
+
+	return m.live.routes.Swap(routes).(*Trie)
+}
+
+// CurrentRoutes returns the routes that this Mux serves, the `Routes` or the last ones passed to `Swap`.
+// It is safe for concurrent use with the `Swap` and the `Handle/HandleFunc`,
+// the returned Trie is not modified by the next registrations, see `Swap`.
+func (m *Mux) CurrentRoutes() *Trie {
+	if !m.live.shared.Load() {
+		m.live.mu.Lock()
+		m.live.shared.Store(true)
+		m.live.mu.Unlock()
+	}
+
+	return m.live.load()
+}
+
+// updateRoutes calls the "fn" to register routes to the routes that the Mux serves
+// or, if they are shared, to a copy of them which replaces them afterwards (copy-on-write),
+// so the requests which are served at the same time never observe a half-modified Trie.
+// The copy is dropped if the "fn" fails.
+func (m *Mux) updateRoutes(fn func(routes *Trie) error) error {
+	m.live.mu.Lock()
+	defer m.live.mu.Unlock()
+
+	if !m.live.shared.Load() {
+		return fn(m.live.load())
 	}
+
+	routes := m.live.load().Clone()
+	if err := fn(routes); err != nil {
+		return err
+	}
+
+	m.live.routes.Store(routes)
+	return nil
 }
 
 // AddRequestHandler adds a full `RequestHandler` which is responsible
@@ -80,19 +257,82 @@ func (m *Mux) HandleRequest(matcher Matcher, handler http.Handler) {
 }
 
 // Use adds middleware that should be called before each mux route's main handler.
-// Should be called before `Handle/HandleFunc`. Order matters.
+// Should be called before `Handle/HandleFunc`, unless the `LazyMiddleware` is true. Order matters.
+// The `Build` reports the routes which were registered before a late `Use` call.
 //
 // A Wrapper is just a type of `func(http.Handler) http.Handler`
 // which is a common type definition for net/http middlewares.
 //
 // To add a middleware for a specific route and not in the whole mux
-// use the `Handle/HandleFunc` with the package-level `muxie.Pre` function instead.
+// use the `Handle/HandleFunc` with the `muxie.With` insert option or the `With` method instead.
 // Functionality of `Use` is pretty self-explained but new gophers should
 // take a look of the examples for further details.
 func (m *Mux) Use(middlewares ...Wrapper) {
+	if !m.LazyMiddleware && len(m.registered) > 0 {
+		m.build.lateRoutes = append(m.build.lateRoutes, m.registered...)
+	}
+	m.registered = nil
+
 	m.beginHandlers = append(m.beginHandlers, middlewares...)
 }
 
+// wrap returns the "handler" wrapped by the `Use` middlewares, see `LazyMiddleware`.
+func (m *Mux) wrap(handler http.Handler) http.Handler {
+	if !m.LazyMiddleware {
+		return Pre(m.beginHandlers...).For(handler)
+	}
+
+	h := &lazyHandler{mux: m, main: handler}
+	m.build.lazyHandlers = append(m.build.lazyHandlers, h)
+	return h
+}
+
+// lazyHandler composes the `Use` middlewares of its Mux with its main handler on its first request,
+// see `Mux#LazyMiddleware`.
+type lazyHandler struct {
+	mux     *Mux
+	main    http.Handler
+	once    sync.Once
+	handler http.Handler
+}
+
+func (h *lazyHandler) compose() {
+	h.once.Do(func() {
+		h.handler = Pre(h.mux.beginHandlers...).For(h.main)
+	})
+}
+
+func (h *lazyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.compose()
+	h.handler.ServeHTTP(w, r)
+}
+
+// Build composes the middlewares of the routes registered with the `LazyMiddleware`,
+// so the first requests do not have to, it should be called after all the `Use` calls and before serving.
+// It panics with all the route conflicts of the `StrictRoutes` joined, if any.
+// It returns an error which lists the routes that the middlewares of a `Use` call were not applied to
+// because they were registered before that call without the `LazyMiddleware`, if any.
+func (m *Mux) Build() error {
+	m.live.mu.Lock()
+	defer m.live.mu.Unlock()
+
+	if len(m.build.routeErrors) > 0 {
+		panic(errors.Join(m.build.routeErrors...))
+	}
+
+	for _, h := range m.build.lazyHandlers {
+		h.compose()
+	}
+	m.build.lazyHandlers = nil
+
+	if len(m.build.lateRoutes) > 0 {
+		return fmt.Errorf("muxie: Use was called after the routes: %s were registered, their middlewares are missing, see LazyMiddleware",
+			strings.Join(m.build.lateRoutes, ", "))
+	}
+
+	return nil
+}
+
 type (
 	// Wrapper is just a type of `func(http.Handler) http.Handler`
 	// which is a common type definition for net/http middlewares.
@@ -133,19 +373,336 @@ func Pre(middleware ...Wrapper) Wrappers {
 }
 
 // Handle registers a route handler for a path pattern.
-func (m *Mux) Handle(pattern string, handler http.Handler) {
-	m.Routes.Insert(m.root+pattern,
+// The path pattern can be prefixed by HTTP method(s), i.e "GET /users/:id" or "POST, PUT /users/:id",
+// the routes of the same path pattern share the same route and the Mux answers
+// with 405 Method Not Allowed and the "Allow" header of all its methods on other methods
+// and with 204 No Content on OPTIONS, unless a handler for the OPTIONS method is registered as well.
+//
+// The optional "options" can set the route's `Tag` and `Data`, i.e `WithTag("user")`,
+// which can be used to build its URL through `URL`.
+// A route has a single tag, the methods of the same path pattern keep the first one,
+// the `Register` returns an `ErrTagExists` for a different tag instead.
+// If `StrictRoutes` is true then a path pattern which conflicts with an already registered one
+// is not registered and the conflict is reported by the `Build`.
+func (m *Mux) Handle(pattern string, handler http.Handler, options ...InsertOption) {
+	if m.StrictRoutes {
+		if err := m.Register(pattern, handler, options...); err != nil {
+			m.live.mu.Lock()
+			m.build.routeErrors = append(m.build.routeErrors, err)
+			m.build.hasRouteErrors.Store(true)
+			m.live.mu.Unlock()
+		}
+		return
+	}
+
+	m.updateRoutes(func(routes *Trie) error {
+		if methods, path := splitMethodPattern(pattern); methods != "" {
+			m.handleMethods(routes, methods, path, handler, options)
+			return nil
+		}
+
+		routes.Insert(m.absPattern(pattern), m.insertOptions(handler, options)...)
+		m.track(m.absPattern(pattern))
+		return nil
+	})
+}
+
+// Register is like `Handle` but it returns a `*RouteError`
+// for duplicate routes, conflicting parameter names, wildcards followed by more path segments,
+// duplicate tags and invalid parameter constraints, instead of overriding an already registered route.
+// See `Trie#TryInsert` too.
+func (m *Mux) Register(pattern string, handler http.Handler, options ...InsertOption) error {
+	return m.updateRoutes(func(routes *Trie) error {
+		return m.register(routes, pattern, handler, options)
+	})
+}
+
+func (m *Mux) register(routes *Trie, pattern string, handler http.Handler, options []InsertOption) error {
+	methods, path := splitMethodPattern(pattern)
+	if methods == "" {
+		if err := routes.TryInsert(m.absPattern(pattern), m.insertOptions(handler, options)...); err != nil {
+			return err
+		}
+
+		m.track(m.absPattern(pattern))
+		return nil
+	}
+
+	input, existing, err := routes.checkPattern(m.absPattern(path))
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		conflictErr := newRouteConflictError(methods+" "+m.absPattern(path), existing, input)
+		// a different method of the same route is not a conflict.
+		if existing.methods == nil || existing.Handler != http.Handler(existing.methods) ||
+			!errors.Is(conflictErr, ErrRouteExists) || existing.methods.hasAny(methods) {
+			return conflictErr
+		}
+	}
+
+	if err = routes.checkTag(methods+" "+m.absPattern(path), existing, options); err != nil {
+		return err
+	}
+
+	m.handleMethods(routes, methods, path, handler, options)
+	return nil
+}
+
+// absPattern returns the path "pattern" prefixed by this Mux's root,
+// the "/" pattern of a SubMux resolves to its root, i.e "/v1", see `Of`.
+func (m *Mux) absPattern(pattern string) string {
+	if pattern == pathSep && m.root != "" {
+		return m.root
+	}
+
+	return m.root + pattern
+}
+
+func (m *Mux) insertOptions(handler http.Handler, options []InsertOption) []InsertOption {
+	route := &Node{Handler: handler}
+	if mh, ok := handler.(*MethodHandler); ok {
+		// the not registered methods are answered by the `MethodNotAllowed` handler of this Mux,
+		// the "mh" itself is not modified, so it can be registered to more than one Mux or prefix.
+		route.Handler = &methodFallbackHandler{
+			MethodHandler: mh,
+			fallback: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				m.methodNotAllowed(mh, false, w, r)
+			}),
+		}
+	}
+	m.applyRouteOptions(route, options)
+
+	return []InsertOption{
 		WithHandler(
-			Pre(m.beginHandlers...).For(handler)))
+			m.wrap(route.Handler)),
+		func(n *Node) {
+			n.main = handler
+			n.Tag = route.Tag
+			n.Data = route.Data
+		},
+	}
+}
+
+// applyRouteOptions applies the insert "options" to the "route" node
+// and wraps its Handler with the `With` middlewares of this Mux and the "options",
+// so they run after the `Use` ones and only for that route.
+func (m *Mux) applyRouteOptions(route *Node, options []InsertOption) {
+	route.wrappers = append(route.wrappers, m.routeWrappers...)
+	for _, opt := range options {
+		opt(route)
+	}
+	route.wrapHandler()
+}
+
+// handleMethods registers the "handler" for the "methods" of the path "pattern" to the "routes",
+// the route's Handler is a `MethodHandler` shared by all of its methods.
+func (m *Mux) handleMethods(routes *Trie, methods, pattern string, handler http.Handler, options []InsertOption) {
+	n := routes.insertNode(m.absPattern(pattern))
+	if n.methods == nil || n.Handler != http.Handler(n.methods) {
+		// the first method of this route or it overrides a route registered for all methods.
+		n.Tag = ""
+		n.Data = nil
+		m.setMethods(n, Methods())
+	} else if m.live.shared.Load() {
+		// the "n" is a copy of a served node, its MethodHandler is served as well.
+		m.setMethods(n, n.methods.clone())
+	}
+
+	route := &Node{Handler: handler, Tag: n.Tag, Data: n.Data}
+	m.applyRouteOptions(route, options)
+
+	n.methods.Handle(methods, m.wrap(route.Handler))
+	n.Tag = route.Tag
+	n.Data = route.Data
+
+	m.track(methods + " " + m.absPattern(pattern))
+}
+
+// setMethods sets the "mh" as the Handler of the "n" route registered by method(s),
+// its not registered methods are answered by the `MethodNotAllowed` handler of this Mux.
+func (m *Mux) setMethods(n *Node, mh *MethodHandler) {
+	mh.DisableHeadFallback = m.DisableHeadFallback
+	mh.fallback = m.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.methodNotAllowed(mh, true, w, r)
+	}))
+
+	n.Handler = mh
+	n.main = mh
+	n.methods = mh
+}
+
+// track keeps the registered route so a late `Use` call can be reported by the `Build`.
+func (m *Mux) track(route string) {
+	if !m.LazyMiddleware {
+		m.registered = append(m.registered, route)
+	}
+}
+
+// methodNotAllowed answers the requests of a route registered by method(s)
+// which has no handler for the request's method.
+// If "autoOptions" is true then the OPTIONS requests are answered with 204 No Content.
+func (m *Mux) methodNotAllowed(mh *MethodHandler, autoOptions bool, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", mh.allowed(autoOptions))
+
+	if autoOptions && r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if n := m.methodNotAllowedHandlers.Search(r.URL.Path, discardParams{}); n != nil {
+		n.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+// NotFound registers the handler which answers the requests that no route matches.
+// The handler is responsible for the requests under this Mux' prefix,
+// so the SubMuxes created by `Of` inherit it, unless they register their own, i.e:
+// mux.NotFound(htmlNotFoundHandler)
+// api := mux.Of("/api")
+// api.NotFound(jsonNotFoundHandler)
+//
+// The handler is wrapped with the middlewares registered through `Use` so far, see `LazyMiddleware`.
+// Defaults to the `http.NotFound`.
+func (m *Mux) NotFound(handler http.Handler) {
+	m.registerPrefixHandler(m.notFoundHandlers, m.wrap(handler))
+}
+
+// MethodNotAllowed registers the handler which answers the requests
+// that match a route registered by method(s) but not the request's method,
+// the "Allow" header is already set when the handler is executed.
+// The handler is responsible for the requests under this Mux' prefix,
+// so the SubMuxes created by `Of` inherit it, unless they register their own.
+//
+// The handler is executed through the middlewares of the route.
+// Defaults to a handler which sends the 405 status code with its status text.
+func (m *Mux) MethodNotAllowed(handler http.Handler) {
+	m.registerPrefixHandler(m.methodNotAllowedHandlers, handler)
+}
+
+func (m *Mux) registerPrefixHandler(handlers *Trie, handler http.Handler) {
+	if handler == nil {
+		panic("muxie/Mux: empty handler")
+	}
+
+	root := m.root
+	if root == "" {
+		handlers.Insert(pathSep, WithHandler(handler))
+	} else {
+		handlers.Insert(root, WithHandler(handler))
+	}
+
+	handlers.Insert(root+pathSep+WildcardParamStart+"path", WithHandler(handler))
+}
+
+// discardParams is a `ParamsSetter` which does not store the parameters.
+type discardParams struct{}
+
+func (discardParams) Set(string, string) {}
+
+// splitMethodPattern splits a "GET /users" pattern to its method(s) and its path pattern.
+// The methods are empty when the pattern is not prefixed by method(s).
+func splitMethodPattern(pattern string) (string, string) {
+	if i := strings.IndexByte(pattern, pathSepB); i > 0 && pattern[i-1] == ' ' {
+		return strings.TrimSpace(pattern[:i]), pattern[i:]
+	}
+
+	return "", pattern
 }
 
 // HandleFunc registers a route handler function for a path pattern.
-func (m *Mux) HandleFunc(pattern string, handlerFunc func(http.ResponseWriter, *http.Request)) {
-	m.Handle(pattern, http.HandlerFunc(handlerFunc))
+func (m *Mux) HandleFunc(pattern string, handlerFunc func(http.ResponseWriter, *http.Request), options ...InsertOption) {
+	m.Handle(pattern, http.HandlerFunc(handlerFunc), options...)
+}
+
+// Mount registers the "handler" to the "prefix" and all of its sub paths, i.e "/debug/pprof" and "/debug/pprof/*path",
+// the handler receives the request with the prefix removed from its URL Path and RawPath,
+// i.e the "/debug/pprof/heap" as "/heap" and the "/debug/pprof" as "/".
+// It can be used to serve third-party handlers, like an admin UI or another Mux, under a prefix:
+// mux.Mount("/admin", adminMux)
+// The "prefix" may contain named parameters, i.e "/users/:id/files", they are available through the `GetParam`
+// and, if `ContextParams` is true, the `ParamsFromContext`, a mounted Mux keeps them before its own ones.
+// The sub path is available through the "path" wildcard parameter as well.
+func (m *Mux) Mount(prefix string, handler http.Handler) {
+	prefix = pathSep + strings.Trim(prefix, pathSep)
+	abs := m.absPattern(prefix)
+
+	segments := 0 // the number of the path segments to strip.
+	if abs != pathSep {
+		segments = strings.Count(abs, pathSep)
+	}
+
+	mounted := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r2 := r.WithContext(r.Context())
+		u := *r.URL
+		u.Path = stripPathSegments(u.Path, segments)
+		if u.RawPath != "" {
+			u.RawPath = stripPathSegments(u.RawPath, segments)
+		}
+		r2.URL = &u
+
+		handler.ServeHTTP(w, r2)
+	})
+
+	wildcard := prefix + pathSep + WildcardParamStart + "path"
+	if prefix == pathSep {
+		wildcard = prefix + WildcardParamStart + "path"
+	}
+
+	m.Handle(prefix, mounted)
+	m.Handle(wildcard, mounted)
+}
+
+// stripPathSegments returns the "p" path without its first "n" path segments,
+// the result has always a leading slash.
+func stripPathSegments(p string, n int) string {
+	i := 0
+	for ; n > 0 && i < len(p); n-- {
+		next := strings.IndexByte(p[i+1:], pathSepB)
+		if next == -1 {
+			return pathSep
+		}
+		i += next + 1
+	}
+
+	if i >= len(p) {
+		return pathSep
+	}
+
+	return p[i:]
+}
+
+// URL returns the path of the route registered with the `WithTag(tag)` option,
+// filled with the "params" key-value pairs, based on the routes that the Mux serves, see `Swap`, i.e:
+// mux.HandleFunc("/users/:id/files/*file", fileHandler, muxie.WithTag("user_file"))
+// mux.URL("user_file", "id", "42", "file", "docs/a.pdf") // "/users/42/files/docs/a.pdf"
+//
+// See `Trie#BuildPath` too.
+func (m *Mux) URL(tag string, params ...string) (string, error) {
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("muxie: %s: odd number of parameters, expected key-value pairs", tag)
+	}
+
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+
+	return m.CurrentRoutes().BuildPath(tag, values)
 }
 
 // ServeHTTP exposes and serves the registered routes.
 func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if m.build.hasRouteErrors.Load() {
+		// the routes of a `StrictRoutes` Mux conflict, see `Build`.
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
 	for _, h := range m.requestHandlers {
 		if h.Match(r) {
 			h.ServeHTTP(w, r)
@@ -153,28 +710,25 @@ func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if m.PathClean && cleanRequestPath(r.URL) && !m.PathCleanNoRedirect {
+		redirect(w, r)
+		return
+	}
+
 	path := r.URL.Path
+	routes := m.CurrentRoutes()
 
 	if m.PathCorrection {
-		if len(path) > 1 && strings.HasSuffix(path, "/") {
+		if len(path) > 1 && strings.HasSuffix(path, "/") && !isTrailingSlashRoute(routes, path, m.CaseInsensitive) {
 			// Remove trailing slash and client-permanent rule for redirection,
 			// if confgiuration allows that and path has an extra slash.
 
 			// update the new path and redirect.
 			// use Trim to ensure there is no open redirect due to two leading slashes
 			r.URL.Path = pathSep + strings.Trim(path, pathSep)
+			path = r.URL.Path
 			if !m.PathCorrectionNoRedirect {
-				url := r.URL.String()
-				method := r.Method
-				// Fixes https://github.com/kataras/iris/issues/921
-				// This is caused for security reasons, imagine a payment shop,
-				// you can't just permantly redirect a POST request, so just 307 (RFC 7231, 6.4.7).
-				if method == http.MethodPost || method == http.MethodPut {
-					http.Redirect(w, r, url, http.StatusTemporaryRedirect)
-					return
-				}
-
-				http.Redirect(w, r, url, http.StatusMovedPermanently)
+				redirect(w, r)
 				return
 			}
 		}
@@ -187,11 +741,68 @@ func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// and it will be compatible with net/http will be introduced to store the params at least,
 	// we don't want to add a third parameter or a global state to this library.
 
+	if m.UseRawPath {
+		path = r.URL.EscapedPath()
+	}
+
 	pw := m.paramsPool.Get().(*Writer)
 	pw.reset(w)
-	n := m.Routes.Search(path, pw)
+	pw.unescape = m.UseRawPath && m.UnescapePathValues
+	var n *Node
+	if m.CaseInsensitive {
+		// fold the static path segments before trying the parameters and wildcards, i.e the root "/*path".
+		n = routes.SearchFold(path, pw)
+		if n != nil && m.CaseInsensitiveRedirect {
+			if canonical := n.CanonicalPath(path); canonical != path {
+				if m.UseRawPath {
+					r.URL.RawPath = canonical
+					r.URL.Path, _ = url.PathUnescape(canonical)
+				} else {
+					r.URL.Path = canonical
+				}
+
+				m.paramsPool.Put(pw)
+				redirect(w, r)
+				return
+			}
+		}
+	} else {
+		n = routes.Search(path, pw)
+	}
+	pw.unescape = false
+
+	if n == nil && m.RedirectTrailingSlash && len(path) > 1 {
+		if routes.search(toggleTrailingSlash(path), discardParams{}, m.CaseInsensitive) != nil {
+			r.URL.Path = toggleTrailingSlash(r.URL.Path)
+			if r.URL.RawPath != "" {
+				r.URL.RawPath = toggleTrailingSlash(r.URL.RawPath)
+			}
+
+			m.paramsPool.Put(pw)
+			redirect(w, r)
+			return
+		}
+	}
+
 	if n != nil {
-		n.Handler.ServeHTTP(pw, r)
+		if len(pw.params) > 0 {
+			if m.PathValues {
+				for _, p := range pw.params {
+					r.SetPathValue(p.Key, p.Value)
+				}
+			}
+
+			if m.ContextParams {
+				// the pw is reused by the next requests, so the context holds a copy of its parameters,
+				// after the parameters of a Mux which mounts this one, if any, see `Mount`.
+				parent := ParamsFromContext(r.Context()).GetAll()
+				r = r.WithContext(context.WithValue(r.Context(), paramsContextKey{}, ParamStore(newParamsSnapshot(parent, pw.params))))
+			}
+		}
+
+		n.Handler.ServeHTTP(pw.optional(), r)
+	} else if n = m.notFoundHandlers.Search(path, discardParams{}); n != nil {
+		n.Handler.ServeHTTP(w, r)
 	} else {
 		http.NotFound(w, r)
 		// or...
@@ -204,13 +815,109 @@ func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	m.paramsPool.Put(pw)
 }
 
+// cleanPath returns the shortest path name equivalent to "p", see `path.Clean`,
+// its trailing slash is kept.
+func cleanPath(p string) string {
+	if p == "" || p[0] != pathSepB {
+		p = pathSep + p
+	}
+
+	cleaned := path.Clean(p)
+	if p[len(p)-1] == pathSepB && cleaned != pathSep {
+		cleaned += pathSep
+	}
+
+	return cleaned
+}
+
+// cleanRequestPath cleans the path of the request URL, see `cleanPath`,
+// and reports whether it was modified.
+func cleanRequestPath(u *url.URL) bool {
+	if u.RawPath != "" {
+		// clean the escaped form so an encoded slash is not resolved as a path separator,
+		// the encoded dots are decoded first so the "/%2e%2e/" is resolved as a dot segment.
+		rawPath := dotsUnescaper.Replace(u.RawPath)
+		cleaned := cleanPath(rawPath)
+		if cleaned == rawPath {
+			return false
+		}
+
+		if p, err := url.PathUnescape(cleaned); err == nil {
+			u.RawPath = cleaned
+			u.Path = p
+			return true
+		}
+	}
+
+	cleaned := cleanPath(u.Path)
+	if cleaned == u.Path {
+		return false
+	}
+
+	u.Path = cleaned
+	u.RawPath = ""
+	return true
+}
+
+// dotsUnescaper decodes the encoded dots of an escaped path, see `cleanRequestPath`.
+var dotsUnescaper = strings.NewReplacer("%2e", ".", "%2E", ".")
+
+// sameHostPath returns the "p" path with a single leading slash,
+// the browsers treat the "//host" and "/\host" paths as a different host.
+func sameHostPath(p string) string {
+	return pathSep + strings.TrimLeft(p, "/\\")
+}
+
+// isTrailingSlashRoute reports whether the "path", which ends with a slash, matches a route registered
+// with a trailing slash, i.e "/docs/", and not a named parameter or a wildcard which catches it, i.e "/*path".
+func isTrailingSlashRoute(routes *Trie, path string, fold bool) bool {
+	n := routes.search(path, discardParams{}, fold)
+	return n != nil && strings.HasSuffix(n.key, pathSep)
+}
+
+// toggleTrailingSlash removes the trailing slash of the "p" path or adds one if it has not.
+func toggleTrailingSlash(p string) string {
+	if p[len(p)-1] == pathSepB {
+		return p[:len(p)-1]
+	}
+
+	return p + pathSep
+}
+
+// redirect redirects the client to the (modified) request URL.
+// The leading slashes of its path are collapsed, so the client
+// is never redirected to another host, i.e "//evil.com".
+func redirect(w http.ResponseWriter, r *http.Request) {
+	r.URL.Path = sameHostPath(r.URL.Path)
+	if r.URL.RawPath != "" {
+		r.URL.RawPath = sameHostPath(r.URL.RawPath)
+	}
+
+	url := r.URL.String()
+	// Fixes https://github.com/kataras/iris/issues/921
+	// This is caused for security reasons, imagine a payment shop,
+	// you can't just permantly redirect a POST request, so just 307 (RFC 7231, 6.4.7).
+	if method := r.Method; method == http.MethodPost || method == http.MethodPut {
+		http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+		return
+	}
+
+	http.Redirect(w, r, url, http.StatusMovedPermanently)
+}
+
 // SubMux is the child of a main Mux.
 type SubMux interface {
 	Of(prefix string) SubMux
 	Unlink() SubMux
 	Use(middlewares ...Wrapper)
-	Handle(pattern string, handler http.Handler)
-	HandleFunc(pattern string, handlerFunc func(http.ResponseWriter, *http.Request))
+	With(middlewares ...Wrapper) SubMux
+	Handle(pattern string, handler http.Handler, options ...InsertOption)
+	HandleFunc(pattern string, handlerFunc func(http.ResponseWriter, *http.Request), options ...InsertOption)
+	Mount(prefix string, handler http.Handler)
+	Register(pattern string, handler http.Handler, options ...InsertOption) error
+	URL(tag string, params ...string) (string, error)
+	NotFound(handler http.Handler)
+	MethodNotAllowed(handler http.Handler)
 	AbsPath() string
 }
 
@@ -241,12 +948,38 @@ func (m *Mux) Of(prefix string) SubMux {
 	// remove any duplication of slashes "/".
 	prefix = pathSep + strings.Trim(m.root+prefix, pathSep)
 
+	return m.child(prefix)
+}
+
+// With returns a new Mux which shares the prefix and the routes of this Mux
+// and its Handle and HandleFunc wrap the route's handler with the "middlewares", i.e:
+// mux.With(auth).HandleFunc("/admin", adminHandler)
+// The above is the same as mux.HandleFunc("/admin", adminHandler, muxie.With(auth)).
+// Unlike the `Use`, the "middlewares" run only for the matched route and method, see the `With` insert option too.
+func (m *Mux) With(middlewares ...Wrapper) SubMux {
+	child := m.child(m.root)
+	child.routeWrappers = append(child.routeWrappers, middlewares...)
+	return child
+}
+
+// child returns a new Mux which inherits the routes and the handlers of this Mux, see `Of` and `With`.
+func (m *Mux) child(root string) *Mux {
 	return &Mux{
-		Routes: m.Routes,
+		Routes:              m.Routes,
+		live:                m.live,
+		DisableHeadFallback: m.DisableHeadFallback,
+		StrictRoutes:        m.StrictRoutes,
+		LazyMiddleware:      m.LazyMiddleware,
+
+		notFoundHandlers:         m.notFoundHandlers,
+		methodNotAllowedHandlers: m.methodNotAllowedHandlers,
 
-		root:            prefix,
-		requestHandlers: m.requestHandlers[0:],
-		beginHandlers:   m.beginHandlers[0:],
+		// the handlers are copied, so a late `Use` of the parent or the child does not modify the other one.
+		root:            root,
+		requestHandlers: append([]RequestHandler(nil), m.requestHandlers...),
+		beginHandlers:   append([]Wrapper(nil), m.beginHandlers...),
+		routeWrappers:   append([]Wrapper(nil), m.routeWrappers...),
+		build:           m.build,
 	}
 }
 
@@ -288,6 +1021,7 @@ breaking changes and etc. This `Unlink`, which will return the same SubMux, it c
 func (m *Mux) Unlink() SubMux {
 	m.requestHandlers = m.requestHandlers[0:0]
 	m.beginHandlers = m.beginHandlers[0:0]
+	m.routeWrappers = nil
 
 	return m
 }
@@ -0,0 +1,47 @@
+package muxie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// benchResponseWriter is a no-op http.ResponseWriter, so the benchmarks measure the Mux only.
+type benchResponseWriter struct {
+	header http.Header
+}
+
+func (w *benchResponseWriter) Header() http.Header         { return w.header }
+func (w *benchResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *benchResponseWriter) WriteHeader(int)             {}
+
+func benchmarkMux(b *testing.B, mux *Mux, path string) {
+	mux.HandleFunc("/users/:id/files/:name", func(w http.ResponseWriter, r *http.Request) {
+		if GetParam(w, "name") == "" {
+			b.Fatalf("%s: parameter not found", path)
+		}
+	})
+
+	w := &benchResponseWriter{header: make(http.Header)}
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		mux.ServeHTTP(w, r)
+	}
+}
+
+// go test -run=XXX -v -bench=BenchmarkMuxServeHTTP -count=3
+func BenchmarkMuxServeHTTP(b *testing.B) {
+	benchmarkMux(b, NewMux(), "/users/42/files/a.pdf")
+}
+
+// go test -run=XXX -v -bench=BenchmarkMuxServeHTTPContextParams -count=3
+func BenchmarkMuxServeHTTPContextParams(b *testing.B) {
+	mux := NewMux()
+	mux.PathValues = true
+	mux.ContextParams = true
+	benchmarkMux(b, mux, "/users/42/files/a.pdf")
+}
@@ -2,12 +2,15 @@ package muxie
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -154,3 +157,727 @@ func TestMuxOf(t *testing.T) {
 	expect(t, http.MethodGet, srv.URL+"/v1").bodyEq("Handler of /v1")
 	expect(t, http.MethodGet, srv.URL+"/v1/hello").bodyEq("Handler of /v1/hello")
 }
+
+func TestMuxRegister(t *testing.T) {
+	mux := NewMux()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	if err := mux.Register("/users/:id", handler); err != nil {
+		t.Fatal(err)
+	}
+
+	v1 := mux.Of("/v1")
+	if err := v1.Register("/users/:id", handler); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mux.Register("/v1/users/:name", handler); !errors.Is(err, ErrParamNameConflict) {
+		t.Fatalf("expected param name conflict error but got: %v", err)
+	}
+
+	if err := v1.Register("/files/*file/info", handler); !errors.Is(err, ErrWildcardNotLast) {
+		t.Fatalf("expected wildcard not last error but got: %v", err)
+	}
+}
+
+func TestMuxStrictRoutes(t *testing.T) {
+	newStrictMux := func() *Mux {
+		mux := NewMux()
+		mux.StrictRoutes = true
+		mux.HandleFunc("/a/:x", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("first"))
+		})
+		// both conflicts are collected, the first routes are kept.
+		mux.Of("/a").HandleFunc("/:y", func(w http.ResponseWriter, r *http.Request) {})
+		mux.HandleFunc("GET /a/:x", func(w http.ResponseWriter, r *http.Request) {})
+		return mux
+	}
+
+	expectConflicts := func(t *testing.T, v interface{}) {
+		t.Helper()
+
+		err, ok := v.(error)
+		if !ok {
+			t.Fatalf("expected to panic with an error but got: %v", v)
+		}
+
+		joined, ok := err.(interface{ Unwrap() []error })
+		if !ok || len(joined.Unwrap()) != 2 {
+			t.Fatalf("expected two joined errors but got: %v", err)
+		}
+
+		expected := "muxie: /a/:y: conflicting parameter names with the already registered /a/:x\n" +
+			"muxie: GET /a/:x: route already registered with the already registered /a/:x"
+		if got := err.Error(); expected != got {
+			t.Fatalf("expected error: '%s' but got: '%s'", expected, got)
+		}
+
+		var routeErr *RouteError
+		if !errors.As(err, &routeErr) || !errors.Is(err, ErrParamNameConflict) || !errors.Is(err, ErrRouteExists) {
+			t.Fatalf("expected the route errors to be unwrapped but got: %v", err)
+		}
+	}
+
+	t.Run("Build", func(t *testing.T) {
+		mux := newStrictMux()
+		for i := 0; i < 2; i++ {
+			func() {
+				defer func() { expectConflicts(t, recover()) }()
+				mux.Build()
+			}()
+		}
+	})
+
+	t.Run("ServeHTTP", func(t *testing.T) {
+		mux := newStrictMux()
+		// every request fails, not just the first one.
+		for i := 0; i < 2; i++ {
+			testHandler(t, mux, http.MethodGet, "/a/42").statusCode(http.StatusInternalServerError)
+		}
+	})
+
+	mux := NewMux()
+	mux.StrictRoutes = true
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {})
+	if err := mux.Build(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMuxRegisterDuplicateTag(t *testing.T) {
+	mux := NewMux()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	if err := mux.Register("GET /users/:id", handler, WithTag("user")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a different method of the same route keeps its tag.
+	if err := mux.Register("PUT /users/:id", handler, WithTag("user")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a route has a single tag.
+	if err := mux.Register("POST /users/:id", handler, WithTag("user2")); !errors.Is(err, ErrTagExists) {
+		t.Fatalf("expected a tag exists error but got: %v", err)
+	}
+
+	mux.HandleFunc("PATCH /users/:id", handler, WithTag("user3"))
+	if url, err := mux.URL("user", "id", "42"); err != nil || url != "/users/42" {
+		t.Fatalf("expected the first tag to be kept but got: %s (%v)", url, err)
+	}
+
+	if err := mux.Register("/accounts/:id", handler, WithTag("user")); !errors.Is(err, ErrTagExists) {
+		t.Fatalf("expected a tag exists error but got: %v", err)
+	}
+
+	if err := mux.Register("DELETE /members/:id", handler, WithTag("user")); !errors.Is(err, ErrTagExists) {
+		t.Fatalf("expected a tag exists error but got: %v", err)
+	}
+}
+
+func TestMuxSwap(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("old"))
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	expect(t, http.MethodGet, srv.URL+"/old").statusCode(http.StatusOK).bodyEq("old")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				testHandler(t, mux, http.MethodGet, "/old")
+			}
+		}()
+	}
+
+	routes := mux.Routes.Clone()
+	routes.Delete("/old")
+	routes.Insert("/new", WithHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new"))
+	})))
+	previous := mux.Swap(routes)
+	wg.Wait()
+
+	if !previous.HasPrefix("/old") {
+		t.Fatalf("expected the previous routes to be returned")
+	}
+
+	expect(t, http.MethodGet, srv.URL+"/old").statusCode(http.StatusNotFound)
+	expect(t, http.MethodGet, srv.URL+"/new").statusCode(http.StatusOK).bodyEq("new")
+}
+
+// TestMuxSwapConcurrent should be run with the -race flag.
+func TestMuxSwapConcurrent(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {}, WithTag("user"))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			mux.Swap(mux.CurrentRoutes().Clone())
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			if _, err := mux.URL("user", "id", "42"); err != nil {
+				t.Error(err)
+				return
+			}
+			if rt := mux.RouteTable(); len(rt) != 1 {
+				t.Errorf("expected one route but got: %d", len(rt))
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	// the routes are registered to a copy of the swapped Trie, which is served.
+	routes := NewTrie()
+	mux.Swap(routes)
+	mux.Of("/v1").HandleFunc("/about", func(w http.ResponseWriter, r *http.Request) {})
+	if routes.HasPrefix("/v1/about") || mux.Routes.HasPrefix("/v1/about") || !mux.CurrentRoutes().HasPrefix("/v1/about") {
+		t.Fatalf("expected the route to be registered to a copy of the swapped routes only")
+	}
+	if rt := mux.RouteTable(); len(rt) != 1 || rt[0].Pattern != "/v1/about" {
+		t.Fatalf("expected the route table of the swapped routes but got: %v", rt)
+	}
+}
+
+func TestMuxHandleWhileServing(t *testing.T) {
+	mux := NewMux()
+	mux.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Use", "1")
+			next.ServeHTTP(w, r)
+		})
+	})
+	mux.HandleFunc("GET /users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + GetParam(w, "id")))
+	})
+
+	// the routes registered before serving are inserted in place.
+	if !mux.Routes.HasPrefix("/users/:id") {
+		t.Fatalf("expected the route to be registered to the Routes")
+	}
+
+	served := mux.CurrentRoutes()
+
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			id := strconv.Itoa(i)
+			mux.HandleFunc("/new/"+id, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("new " + id))
+			})
+			mux.HandleFunc("DELETE /users/:id", func(w http.ResponseWriter, r *http.Request) {})
+			if err := mux.Register("POST /posts/"+id, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			testHandler(t, mux, http.MethodGet, "/users/42").statusCode(http.StatusOK).bodyEq("user 42")
+		}
+	}()
+	wg.Wait()
+
+	if served.HasPrefix("/new/0") {
+		t.Fatalf("expected the served routes to not be modified")
+	}
+
+	testHandler(t, mux, http.MethodGet, "/new/7").statusCode(http.StatusOK).
+		headerEq("X-Use", "1").bodyEq("new 7")
+	testHandler(t, mux, http.MethodPut, "/users/42").statusCode(http.StatusMethodNotAllowed).
+		headerEq("Allow", "GET, DELETE, HEAD, OPTIONS")
+	testHandler(t, mux, http.MethodPost, "/posts/7").statusCode(http.StatusOK)
+}
+
+func TestMuxURL(t *testing.T) {
+	mux := NewMux()
+	v1 := mux.Of("/v1")
+	v1.HandleFunc("/users/:id/files/*file", func(w http.ResponseWriter, r *http.Request) {
+		url, err := mux.URL("user_file", "id", GetParam(w, "id"), "file", GetParam(w, "file"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.Write([]byte(url))
+	}, WithTag("user_file"))
+
+	testHandler(t, mux, http.MethodGet, "/v1/users/42/files/docs/a.pdf").statusCode(http.StatusOK).
+		bodyEq("/v1/users/42/files/docs/a.pdf")
+
+	if _, err := v1.URL("user_file", "id", "42"); err == nil {
+		t.Fatalf("expected error for missing parameter")
+	}
+
+	if _, err := mux.URL("user_file", "id"); err == nil {
+		t.Fatalf("expected error for odd number of parameters")
+	}
+
+	routes := NewTrie()
+	routes.Insert("/v2/users/:id", WithTag("user_file"))
+	mux.Swap(routes)
+	if url, err := mux.URL("user_file", "id", "42"); err != nil || url != "/v2/users/42" {
+		t.Fatalf("expected the URL of the swapped routes but got: '%s' (%v)", url, err)
+	}
+}
+
+func TestMuxNotFoundAndMethodNotAllowed(t *testing.T) {
+	mux := NewMux()
+	mux.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Middleware", "global")
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("/methods", Methods().HandleFunc(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {}))
+	// created before the parent's NotFound, it still inherits it.
+	docs := mux.Of("/docs")
+	docs.HandleFunc("/index", func(w http.ResponseWriter, r *http.Request) {})
+
+	mux.NotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("<h1>Not Found</h1>"))
+	}))
+
+	api := mux.Of("/api")
+	api.HandleFunc("GET /users/:id", func(w http.ResponseWriter, r *http.Request) {})
+	api.NotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"title":"Not Found"}`))
+	}))
+	api.MethodNotAllowed(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte(`{"title":"Method Not Allowed"}`))
+	}))
+
+	testHandler(t, mux, http.MethodGet, "/unknown").statusCode(http.StatusNotFound).
+		headerEq("X-Middleware", "global").bodyEq("<h1>Not Found</h1>")
+	testHandler(t, mux, http.MethodGet, "/docs/unknown").statusCode(http.StatusNotFound).
+		bodyEq("<h1>Not Found</h1>")
+	testHandler(t, mux, http.MethodGet, "/api").statusCode(http.StatusNotFound).
+		headerEq("X-Middleware", "global").bodyEq(`{"title":"Not Found"}`)
+	testHandler(t, mux, http.MethodGet, "/api/users/42/unknown").statusCode(http.StatusNotFound).
+		bodyEq(`{"title":"Not Found"}`)
+
+	testHandler(t, mux, http.MethodPost, "/api/users/42").statusCode(http.StatusMethodNotAllowed).
+		headerEq("Allow", "GET, HEAD, OPTIONS").headerEq("X-Middleware", "global").
+		bodyEq(`{"title":"Method Not Allowed"}`)
+	testHandler(t, mux, http.MethodPost, "/").statusCode(http.StatusMethodNotAllowed).
+		headerEq("Allow", "GET, HEAD, OPTIONS").bodyEq("Method Not Allowed\n")
+	testHandler(t, mux, http.MethodPost, "/methods").statusCode(http.StatusMethodNotAllowed).
+		headerEq("Allow", "GET, HEAD").bodyEq("Method Not Allowed\n")
+}
+
+func TestMuxUseRawPath(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(GetParam(w, "name") + ":" + r.PathValue("name")))
+	}
+
+	mux := NewMux()
+	mux.PathValues = true
+	mux.HandleFunc("/files/:name", handler)
+	mux.HandleFunc("/files/:name/info", handler)
+
+	testHandler(t, mux, http.MethodGet, "/files/a%2Fb").statusCode(http.StatusNotFound)
+	testHandler(t, mux, http.MethodGet, "/files/a%2Fb/info").statusCode(http.StatusNotFound)
+
+	mux.UseRawPath = true
+	testHandler(t, mux, http.MethodGet, "/files/a%2Fb").statusCode(http.StatusOK).bodyEq("a%2Fb:a%2Fb")
+	testHandler(t, mux, http.MethodGet, "/files/a%2Fb/info").statusCode(http.StatusOK).bodyEq("a%2Fb:a%2Fb")
+
+	mux.UnescapePathValues = true
+	testHandler(t, mux, http.MethodGet, "/files/a%2Fb").statusCode(http.StatusOK).bodyEq("a/b:a/b")
+	testHandler(t, mux, http.MethodGet, "/files/a%20b%25/info").statusCode(http.StatusOK).bodyEq("a b%:a b%")
+	testHandler(t, mux, http.MethodGet, "/files/plain").statusCode(http.StatusOK).bodyEq("plain:plain")
+}
+
+func TestMuxCaseInsensitive(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc("/about/:name", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("about " + GetParam(w, "name")))
+	})
+
+	testHandler(t, mux, http.MethodGet, "/About/Kataras").statusCode(http.StatusNotFound)
+
+	mux.CaseInsensitive = true
+	testHandler(t, mux, http.MethodGet, "/About/Kataras").statusCode(http.StatusOK).bodyEq("about Kataras")
+
+	mux.CaseInsensitiveRedirect = true
+	testHandler(t, mux, http.MethodGet, "/about/Kataras").statusCode(http.StatusOK).bodyEq("about Kataras")
+	testHandler(t, mux, http.MethodGet, "/ABOUT/Kataras?lang=en").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/about/Kataras?lang=en")
+	testHandler(t, mux, http.MethodPost, "/ABOUT/Kataras").statusCode(http.StatusTemporaryRedirect).
+		headerEq("Location", "/about/Kataras")
+
+	// the static path segments are folded before a wildcard catches the path.
+	mux = NewMux()
+	mux.CaseInsensitive = true
+	mux.HandleFunc("/about", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("about"))
+	})
+	mux.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + GetParam(w, "id")))
+	})
+	mux.HandleFunc("/*path", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("wildcard " + GetParam(w, "path")))
+	})
+
+	testHandler(t, mux, http.MethodGet, "/ABOUT").statusCode(http.StatusOK).bodyEq("about")
+	testHandler(t, mux, http.MethodGet, "/Users/Kataras").statusCode(http.StatusOK).bodyEq("user Kataras")
+	testHandler(t, mux, http.MethodGet, "/other/Path").statusCode(http.StatusOK).bodyEq("wildcard other/Path")
+
+	mux.CaseInsensitiveRedirect = true
+	testHandler(t, mux, http.MethodGet, "/About").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/about")
+}
+
+func TestMuxCaseInsensitiveRedirectSameHost(t *testing.T) {
+	mux := NewMux()
+	mux.CaseInsensitive = true
+	mux.CaseInsensitiveRedirect = true
+	mux.HandleFunc("/:a/:b/Foo", func(w http.ResponseWriter, r *http.Request) {})
+
+	testHandler(t, mux, http.MethodGet, "//evil.com/foo").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/evil.com/Foo")
+	testHandler(t, mux, http.MethodGet, "/\\/evil.com/foo").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/evil.com/Foo")
+}
+
+func TestMuxPathClean(t *testing.T) {
+	mux := NewMux()
+	mux.PathClean = true
+	mux.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + GetParam(w, "id")))
+	})
+	mux.HandleFunc("/admin", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("admin"))
+	})
+
+	testHandler(t, mux, http.MethodGet, "//users///42?sort=asc").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/users/42?sort=asc")
+	testHandler(t, mux, http.MethodPost, "/a/../admin").statusCode(http.StatusTemporaryRedirect).
+		headerEq("Location", "/admin")
+	testHandler(t, mux, http.MethodGet, "/users/./42/").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/users/42/")
+	testHandler(t, mux, http.MethodGet, "/users/42").statusCode(http.StatusOK).bodyEq("user 42")
+
+	// the encoded dot segments are cleaned as well.
+	testHandler(t, mux, http.MethodGet, "/users/%2e%2e/admin").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/admin")
+	testHandler(t, mux, http.MethodGet, "/users/%2E%2E/admin").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/admin")
+	testHandler(t, mux, http.MethodGet, "/users/42%2e").statusCode(http.StatusOK).bodyEq("user 42.")
+
+	mux.PathCleanNoRedirect = true
+	testHandler(t, mux, http.MethodGet, "//users///42").statusCode(http.StatusOK).bodyEq("user 42")
+	testHandler(t, mux, http.MethodGet, "/users/../../admin").statusCode(http.StatusOK).bodyEq("admin")
+
+	mux.UseRawPath = true
+	testHandler(t, mux, http.MethodGet, "/users//a%2F..%2Fb").statusCode(http.StatusOK).bodyEq("user a%2F..%2Fb")
+}
+
+func TestMuxPathCleanEncodedDots(t *testing.T) {
+	mux := NewMux()
+	mux.PathClean = true
+	mux.HandleFunc("/public/*file", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("file " + GetParam(w, "file")))
+	})
+
+	admin := mux.Of("/admin")
+	admin.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		})
+	})
+	admin.HandleFunc("/secret", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	})
+
+	for _, dots := range []string{"%2e%2e", "%2E%2E", "%2e."} {
+		testHandler(t, mux, http.MethodGet, "/public/"+dots+"/admin/secret").statusCode(http.StatusMovedPermanently).
+			headerEq("Location", "/admin/secret")
+	}
+
+	mux.PathCleanNoRedirect = true
+	for _, dots := range []string{"%2e%2e", "%2E%2E", "%2e."} {
+		testHandler(t, mux, http.MethodGet, "/public/"+dots+"/admin/secret").statusCode(http.StatusUnauthorized)
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"users", "/users"},
+		{"//users///42", "/users/42"},
+		{"/a/../admin", "/admin"},
+		{"/../..", "/"},
+		{"/docs/./intro/", "/docs/intro/"},
+		{"//", "/"},
+	}
+
+	for i, tt := range tests {
+		if got := cleanPath(tt.path); got != tt.expected {
+			t.Fatalf("[%d] expected '%s' to be cleaned to: '%s' but got: '%s'", i, tt.path, tt.expected, got)
+		}
+	}
+}
+
+func TestMuxRedirectTrailingSlash(t *testing.T) {
+	mux := NewMux()
+	mux.PathCorrection = true
+	mux.HandleFunc("/docs/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("docs"))
+	})
+	mux.HandleFunc("/about", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("about"))
+	})
+
+	v1 := mux.Of("/v1")
+	v1.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("v1"))
+	})
+
+	testHandler(t, mux, http.MethodGet, "/docs/").statusCode(http.StatusOK).bodyEq("docs")
+	testHandler(t, mux, http.MethodGet, "/docs").statusCode(http.StatusNotFound)
+	testHandler(t, mux, http.MethodGet, "/about/").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/about")
+	testHandler(t, mux, http.MethodGet, "/v1").statusCode(http.StatusOK).bodyEq("v1")
+
+	mux.RedirectTrailingSlash = true
+	testHandler(t, mux, http.MethodGet, "/docs?page=2").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/docs/?page=2")
+	testHandler(t, mux, http.MethodPost, "/docs").statusCode(http.StatusTemporaryRedirect).
+		headerEq("Location", "/docs/")
+
+	mux.PathCorrection = false
+	testHandler(t, mux, http.MethodGet, "/about/").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/about")
+	testHandler(t, mux, http.MethodGet, "/unknown/").statusCode(http.StatusNotFound)
+
+	mux.PathCorrection = true
+	mux.PathCorrectionNoRedirect = true
+	testHandler(t, mux, http.MethodGet, "/about/").statusCode(http.StatusOK).bodyEq("about")
+
+	mux.CaseInsensitive = true
+	testHandler(t, mux, http.MethodGet, "/Docs").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/Docs/")
+	testHandler(t, mux, http.MethodGet, "/Docs/").statusCode(http.StatusOK).bodyEq("docs")
+}
+
+func TestMuxRedirectTrailingSlashSameHost(t *testing.T) {
+	mux := NewMux()
+	mux.RedirectTrailingSlash = true
+	mux.HandleFunc("/:a/:b/", func(w http.ResponseWriter, r *http.Request) {})
+
+	testHandler(t, mux, http.MethodGet, "//evil.com").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/evil.com/")
+	testHandler(t, mux, http.MethodGet, "/\\/evil.com").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/evil.com/")
+}
+
+func TestMuxPathCorrectionRootWildcard(t *testing.T) {
+	mux := NewMux()
+	mux.PathCorrection = true
+	mux.HandleFunc("/docs", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("docs"))
+	})
+	mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("blog"))
+	})
+	mux.HandleFunc("/*path", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("wildcard " + GetParam(w, "path")))
+	})
+
+	testHandler(t, mux, http.MethodGet, "/docs/").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/docs")
+	testHandler(t, mux, http.MethodGet, "/blog/").statusCode(http.StatusOK).bodyEq("blog")
+	testHandler(t, mux, http.MethodGet, "/other/").statusCode(http.StatusMovedPermanently).
+		headerEq("Location", "/other")
+
+	mux.PathCorrectionNoRedirect = true
+	testHandler(t, mux, http.MethodGet, "/docs/").statusCode(http.StatusOK).bodyEq("docs")
+}
+
+func TestMuxWith(t *testing.T) {
+	headerMiddleware := func(value string) Wrapper {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Middleware", value)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Join(w.Header().Values("X-Middleware"), ",")))
+	}
+
+	mux := NewMux()
+	mux.Use(headerMiddleware("global"))
+	mux.HandleFunc("/public", handler)
+	mux.HandleFunc("/admin", handler, With(headerMiddleware("auth"), headerMiddleware("ratelimit")), WithTag("admin"))
+	mux.HandleFunc("GET /users", handler)
+	mux.HandleFunc("POST /users", handler, With(headerMiddleware("auth")))
+
+	authenticated := mux.With(headerMiddleware("auth"))
+	authenticated.HandleFunc("/settings", handler, With(headerMiddleware("settings")))
+	authenticated.Of("/v1").HandleFunc("/profile", handler)
+
+	testHandler(t, mux, http.MethodGet, "/public").bodyEq("global")
+	testHandler(t, mux, http.MethodGet, "/admin").bodyEq("global,auth,ratelimit")
+	testHandler(t, mux, http.MethodGet, "/users").bodyEq("global")
+	testHandler(t, mux, http.MethodPost, "/users").bodyEq("global,auth")
+	testHandler(t, mux, http.MethodDelete, "/users").statusCode(http.StatusMethodNotAllowed).
+		headerEq("X-Middleware", "global")
+	testHandler(t, mux, http.MethodGet, "/settings").bodyEq("global,auth,settings")
+	testHandler(t, mux, http.MethodGet, "/v1/profile").bodyEq("global,auth")
+
+	if u, err := mux.URL("admin"); err != nil || u != "/admin" {
+		t.Fatalf("expected the tag of the route with middlewares to be kept but got: '%s' (%v)", u, err)
+	}
+}
+
+func TestMuxLazyMiddleware(t *testing.T) {
+	headerMiddleware := func(value string) Wrapper {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Middleware", value)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Join(w.Header().Values("X-Middleware"), ",")))
+	}
+
+	mux := NewMux()
+	mux.LazyMiddleware = true
+	mux.Use(headerMiddleware("first"))
+	mux.HandleFunc("/users", handler)
+	mux.HandleFunc("GET /posts", handler)
+	mux.NotFound(http.HandlerFunc(handler))
+	api := mux.Of("/api")
+	api.HandleFunc("/status", handler)
+	mux.Use(headerMiddleware("late"))
+	api.Use(headerMiddleware("api"))
+
+	if err := mux.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	testHandler(t, mux, http.MethodGet, "/users").bodyEq("first,late")
+	testHandler(t, mux, http.MethodGet, "/posts").bodyEq("first,late")
+	testHandler(t, mux, http.MethodPost, "/posts").statusCode(http.StatusMethodNotAllowed).
+		headerEq("X-Middleware", "first")
+	testHandler(t, mux, http.MethodGet, "/unknown").bodyEq("first,late")
+	testHandler(t, mux, http.MethodGet, "/api/status").bodyEq("first,api")
+
+	eager := NewMux()
+	eager.HandleFunc("/health", handler)
+	eager.HandleFunc("GET /users", handler)
+	eager.Use(headerMiddleware("first"))
+	eager.HandleFunc("/posts", handler)
+	eager.Use(headerMiddleware("second"))
+
+	testHandler(t, eager, http.MethodGet, "/health").bodyEq("")
+	testHandler(t, eager, http.MethodGet, "/posts").bodyEq("first")
+
+	err := eager.Build()
+	if err == nil {
+		t.Fatalf("expected an error for the routes registered before the Use calls")
+	}
+
+	if expected, got := "muxie: Use was called after the routes: /health, GET /users, /posts were registered, their middlewares are missing, see LazyMiddleware", err.Error(); expected != got {
+		t.Fatalf("expected error: '%s' but got: '%s'", expected, got)
+	}
+}
+
+func TestMuxMount(t *testing.T) {
+	printPathHandler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s:%s:%s", r.URL.Path, r.URL.RawPath, GetParam(w, "id"))
+	}
+
+	admin := NewMux()
+	admin.HandleFunc("/", printPathHandler)
+	admin.HandleFunc("/users/:id", printPathHandler)
+
+	mux := NewMux()
+	mux.Mount("/admin/", admin)
+	mux.Of("/v1").Mount("/users/:id/files", http.HandlerFunc(printPathHandler))
+
+	testHandler(t, mux, http.MethodGet, "/admin").bodyEq("/::")
+	testHandler(t, mux, http.MethodGet, "/admin/").bodyEq("/::")
+	testHandler(t, mux, http.MethodGet, "/admin/users/42").bodyEq("/users/42::42")
+	testHandler(t, mux, http.MethodGet, "/administrator").statusCode(http.StatusNotFound)
+	testHandler(t, mux, http.MethodGet, "/v1/users/42/files/docs/a%2Fb.pdf").bodyEq("/docs/a/b.pdf:/docs/a%2Fb.pdf:42")
+
+	root := NewMux()
+	root.Mount("/", http.HandlerFunc(printPathHandler))
+	testHandler(t, root, http.MethodGet, "/").bodyEq("/::")
+	testHandler(t, root, http.MethodGet, "/a/b").bodyEq("/a/b::")
+}
+
+func TestMuxMountParams(t *testing.T) {
+	files := NewMux()
+	files.ContextParams = true
+	files.HandleFunc("/:name", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s:%s:%v:%v", GetParam(w, "id"), GetParam(w, "name"),
+			GetParams(w), ParamsFromContext(r.Context()).GetAll())
+	})
+	files.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s:%s", GetParam(w, "id"), ParamsFromContext(r.Context()).Get("id"))
+	})
+
+	mux := NewMux()
+	mux.ContextParams = true
+	mux.Mount("/users/:id/files", files)
+
+	testHandler(t, mux, http.MethodGet, "/users/42/files/a.pdf").statusCode(http.StatusOK).
+		bodyEq("42:a.pdf:[{id 42} {path a.pdf} {name a.pdf}]:[{id 42} {path a.pdf} {name a.pdf}]")
+	testHandler(t, mux, http.MethodGet, "/users/42/files").statusCode(http.StatusOK).bodyEq("42:42")
+}
+
+func TestStripPathSegments(t *testing.T) {
+	tests := []struct {
+		path     string
+		n        int
+		expected string
+	}{
+		{"/admin", 1, "/"},
+		{"/admin/", 1, "/"},
+		{"/admin/users/42", 1, "/users/42"},
+		{"/a/b/c/", 2, "/c/"},
+		{"/a", 0, "/a"},
+		{"", 0, "/"},
+	}
+
+	for i, tt := range tests {
+		if got := stripPathSegments(tt.path, tt.n); got != tt.expected {
+			t.Fatalf("[%d] expected '%s' without %d segments to be: '%s' but got: '%s'", i, tt.path, tt.n, tt.expected, got)
+		}
+	}
+}
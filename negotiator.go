@@ -0,0 +1,241 @@
+package muxie
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNotAcceptable is the `NegotiationError.Err` when none of the registered media types
+	// is accepted by the client's "Accept" header.
+	ErrNotAcceptable = errors.New("not acceptable")
+	// ErrUnsupportedMediaType is the `NegotiationError.Err` when the request's "Content-Type"
+	// is not one of the registered media types.
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+)
+
+// NegotiationError describes why a `Negotiator` could not pick a `Processor`.
+// It can be sent to the client through `WriteError`, as 406 or 415 `Problem`.
+type NegotiationError struct {
+	// MediaType is the request's "Accept" or "Content-Type" header value.
+	MediaType string
+	// Offers are the registered media types.
+	Offers []string
+	// Err is the `ErrNotAcceptable` or the `ErrUnsupportedMediaType`.
+	Err error
+}
+
+func (e *NegotiationError) Error() string {
+	return fmt.Sprintf("muxie: %v %q, supported: %s", e.Err, e.MediaType, strings.Join(e.Offers, ", "))
+}
+
+// Unwrap returns the reason of the error, so it can be checked with `errors.Is`.
+func (e *NegotiationError) Unwrap() error {
+	return e.Err
+}
+
+// Problem returns the 406 or 415 `Problem` of the error, see `WriteError`.
+func (e *NegotiationError) Problem() *Problem {
+	status := http.StatusNotAcceptable
+	if e.Err == ErrUnsupportedMediaType {
+		status = http.StatusUnsupportedMediaType
+	}
+
+	return NewProblem(status, fmt.Sprintf("%v %q, supported: %s", e.Err, e.MediaType, strings.Join(e.Offers, ", ")))
+}
+
+// Negotiator holds `Processor`s by their media type and picks the one that the client asks for.
+// It implements the `Binder` based on the request's "Content-Type" header
+// and, through its `For` method, the `Dispatcher` based on the request's "Accept" header, i.e:
+//
+//	negotiator := muxie.NewNegotiator().
+//	    Register("application/json", muxie.JSON).
+//	    Register("application/xml", muxie.XML)
+//
+//	if err := muxie.Bind(r, negotiator, &user); err != nil {
+//	    muxie.WriteError(w, err)
+//	    return
+//	}
+//	muxie.Dispatch(w, negotiator.For(r), user)
+//
+// The first registered media type is used when the client does not send an "Accept" header.
+type Negotiator struct {
+	mediaTypes []string
+	processors map[string]Processor
+}
+
+var _ Binder = (*Negotiator)(nil)
+
+func (n *Negotiator) bindsBody() bool { return true }
+
+// NewNegotiator returns a new, empty, `Negotiator`, see `Register`.
+func NewNegotiator() *Negotiator {
+	return &Negotiator{processors: make(map[string]Processor)}
+}
+
+// Register adds the "p" `Processor` for the "mediaType", i.e "application/json".
+// A registration with the same media type overrides the previous one. Order matters.
+// It returns the Negotiator itself, so calls can be chained.
+func (n *Negotiator) Register(mediaType string, p Processor) *Negotiator {
+	if mediaType == "" || p == nil {
+		panic("muxie/Negotiator#Register: empty media type or processor")
+	}
+
+	mediaType = strings.ToLower(mediaType)
+	if _, exists := n.processors[mediaType]; !exists {
+		n.mediaTypes = append(n.mediaTypes, mediaType)
+	}
+	n.processors[mediaType] = p
+
+	return n
+}
+
+// MediaTypes returns the registered media types, in order.
+func (n *Negotiator) MediaTypes() []string {
+	return n.mediaTypes
+}
+
+// Bind binds the request body to the "v" through the `Processor` of the request's "Content-Type",
+// it returns a `NegotiationError` of `ErrUnsupportedMediaType` when there is not such a Processor.
+// A request without a "Content-Type" is bound through the first registered Processor
+// or, if its body is empty, it is not bound at all and the "v" is left as it is.
+func (n *Negotiator) Bind(r *http.Request, v interface{}) error {
+	contentType := r.Header.Get("Content-Type")
+	if strings.TrimSpace(contentType) == "" {
+		if isEmptyBody(r) {
+			return nil
+		}
+
+		if len(n.mediaTypes) > 0 {
+			return n.processors[n.mediaTypes[0]].Bind(r, v)
+		}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		if p, ok := n.processors[mediaType]; ok {
+			return p.Bind(r, v)
+		}
+	}
+
+	return &NegotiationError{MediaType: contentType, Offers: n.mediaTypes, Err: ErrUnsupportedMediaType}
+}
+
+// Negotiate returns the registered media type and its `Processor`
+// which is the most preferred by the "accept" header value, i.e "application/xml;q=0.9, */*;q=0.1",
+// it returns a `NegotiationError` of `ErrNotAcceptable` when none of them is accepted.
+// An empty "accept" accepts the first registered media type.
+func (n *Negotiator) Negotiate(accept string) (string, Processor, error) {
+	if len(n.mediaTypes) > 0 {
+		if strings.TrimSpace(accept) == "" {
+			mediaType := n.mediaTypes[0]
+			return mediaType, n.processors[mediaType], nil
+		}
+
+		ranges := parseAccept(accept)
+
+		best, bestQ := "", 0.0
+		for _, mediaType := range n.mediaTypes { // on equal quality the first registered one wins.
+			if q := acceptQuality(ranges, mediaType); q > bestQ {
+				best, bestQ = mediaType, q
+			}
+		}
+
+		if best != "" {
+			return best, n.processors[best], nil
+		}
+	}
+
+	return "", nil, &NegotiationError{MediaType: accept, Offers: n.mediaTypes, Err: ErrNotAcceptable}
+}
+
+// For returns a `Processor` bound to the "r" request,
+// its Dispatch uses the Processor that the request's "Accept" header prefers, see `Negotiate`,
+// and its Bind is the Negotiator's one.
+func (n *Negotiator) For(r *http.Request) Processor {
+	return &negotiatedProcessor{n: n, r: r}
+}
+
+type negotiatedProcessor struct {
+	n *Negotiator
+	r *http.Request
+}
+
+var _ Processor = (*negotiatedProcessor)(nil)
+
+func (p *negotiatedProcessor) Bind(r *http.Request, v interface{}) error {
+	return p.n.Bind(r, v)
+}
+
+func (p *negotiatedProcessor) Dispatch(w http.ResponseWriter, v interface{}) error {
+	_, processor, err := p.n.Negotiate(p.r.Header.Get("Accept"))
+	if err != nil {
+		return err
+	}
+
+	w.Header().Add("Vary", "Accept")
+	return processor.Dispatch(w, v)
+}
+
+// acceptRange is a media range of an "Accept" header, i.e "text/*;q=0.5".
+type acceptRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses the media ranges of an "Accept" header value, the invalid ones are skipped.
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, acceptRange{typ: typ, subtype: subtype, q: q})
+	}
+
+	return ranges
+}
+
+// acceptQuality returns the quality of the "mediaType" based on the most specific media range which matches it,
+// zero means not acceptable.
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		}
+
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+
+	return q
+}
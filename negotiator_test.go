@@ -0,0 +1,116 @@
+package muxie
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiatorNegotiate(t *testing.T) {
+	n := NewNegotiator().
+		Register("application/json", JSON).
+		Register("application/xml", XML).
+		Register("text/xml", XML)
+
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"application/xml", "application/xml"},
+		{"application/xml;q=0.9, application/json;q=0.8", "application/xml"},
+		{"text/*, application/json;q=0.5", "text/xml"},
+		{"application/*;q=0.2, application/xml;q=0", "application/json"},
+		{"text/html, */*;q=0.1", "application/json"},
+		{"APPLICATION/XML", "application/xml"},
+		{"text/html", ""},
+		{"application/json;q=0", ""},
+	}
+
+	for i, tt := range tests {
+		mediaType, _, err := n.Negotiate(tt.accept)
+		if tt.expected == "" {
+			if !errors.Is(err, ErrNotAcceptable) {
+				t.Fatalf("[%d] %s: expected not acceptable error but got: %v", i, tt.accept, err)
+			}
+			continue
+		}
+
+		if err != nil || mediaType != tt.expected {
+			t.Fatalf("[%d] %s: expected media type: '%s' but got: '%s' (%v)", i, tt.accept, tt.expected, mediaType, err)
+		}
+	}
+}
+
+func TestNegotiator(t *testing.T) {
+	type user struct {
+		Name string `json:"name" xml:"name"`
+	}
+
+	n := NewNegotiator().
+		Register("application/json", JSON).
+		Register("application/xml", XML)
+
+	mux := NewMux()
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		var u user
+		if err := Bind(r, n, &u); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		if err := Dispatch(w, n.For(r), u); err != nil {
+			WriteError(w, err)
+		}
+	})
+
+	request := func(contentType, accept, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request("application/json; charset=utf-8", "application/xml", `{"name":"kataras"}`)
+	if expected, got := "<user><name>kataras</name></user>", w.Body.String(); expected != got {
+		t.Fatalf("expected body: '%s' but got: '%s'", expected, got)
+	}
+	if expected, got := "Accept", w.Header().Get("Vary"); expected != got {
+		t.Fatalf("expected Vary header: '%s' but got: '%s'", expected, got)
+	}
+
+	w = request("application/xml", "", `<user><name>kataras</name></user>`)
+	if expected, got := `{"name":"kataras"}`, w.Body.String(); expected != got {
+		t.Fatalf("expected body: '%s' but got: '%s'", expected, got)
+	}
+
+	// without a "Content-Type" the body is bound through the first registered processor.
+	w = request("", "application/xml", `{"name":"kataras"}`)
+	if expected, got := "<user><name>kataras</name></user>", w.Body.String(); expected != got {
+		t.Fatalf("expected body: '%s' but got: '%s'", expected, got)
+	}
+
+	// and an empty body is not bound at all.
+	w = request("", "", "")
+	if expected, got := `{"name":""}`, w.Body.String(); w.Code != http.StatusOK || expected != got {
+		t.Fatalf("expected 200 and body: '%s' but got: %d '%s'", expected, w.Code, got)
+	}
+
+	w = request("text/plain", "", "kataras")
+	if w.Code != http.StatusUnsupportedMediaType || w.Header().Get("Content-Type") != ProblemContentType {
+		t.Fatalf("expected 415 problem but got: %d %s", w.Code, w.Body.String())
+	}
+
+	w = request("application/json", "text/html", `{"name":"kataras"}`)
+	if w.Code != http.StatusNotAcceptable {
+		t.Fatalf("expected 406 but got: %d %s", w.Code, w.Body.String())
+	}
+	if expected, got := `{"title":"Not Acceptable","status":406,"detail":"not acceptable \"text/html\", supported: application/json, application/xml"}`, w.Body.String(); expected != got {
+		t.Fatalf("expected body: '%s' but got: '%s'", expected, got)
+	}
+}
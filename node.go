@@ -1,7 +1,10 @@
 package muxie
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"sort"
 	"strings"
 )
@@ -9,24 +12,33 @@ import (
 // Node is the trie's node which path patterns with their data like an HTTP handler are saved to.
 // See `Trie` too.
 type Node struct {
-	parent *Node
+	parent  *Node
+	segment string // the path segment of this node, ":" for named parameters and "*" for wildcards.
 
 	children               map[string]*Node
-	hasDynamicChild        bool // does one of the children contains a parameter or wildcard?
-	childNamedParameter    bool // is the child a named parameter (single segmnet)
-	childWildcardParameter bool // or it is a wildcard (can be more than one path segments) ?
+	paramChildren          []*Node // the named parameter children, constrained first.
+	childWildcardParameter bool    // is one of the children a wildcard (can be more than one path segments) ?
+
+	// the static children by their lower case path segment, see `Trie#SearchFold`.
+	foldChildren map[string]*Node
+
+	// if this is a named parameter node then it may accept only values that pass the constraint, see `ParamConstraint`.
+	paramConstraint     ParamConstraint
+	paramConstraintExpr string
 
 	paramKeys []string // the param keys without : or *.
 	end       bool     // it is a complete node, here we stop and we can say that the node is valid.
 	key       string   // if end == true then key is filled with the original value of the insertion's key.
-	// if key != "" && its parent has childWildcardParameter == true,
-	// we need it to track the static part for the closest-wildcard's parameter storage.
-	staticKey string
 
 	// insert main data relative to http and a tag for things like route names.
 	Handler http.Handler
+	main    http.Handler   // the Handler before the `Mux` middlewares, if registered through a Mux.
+	methods *MethodHandler // the Handler of the routes registered through a Mux with a method, i.e "GET /users".
 	Tag     string
 
+	// the middlewares of the `With` insert option, they wrap the Handler when the insert options are applied.
+	wrappers []Wrapper
+
 	// other insert data.
 	Data interface{}
 }
@@ -48,6 +60,15 @@ func (n *Node) addChild(s string, child *Node) {
 
 	child.parent = n
 	n.children[s] = child
+
+	if n.foldChildren == nil {
+		n.foldChildren = make(map[string]*Node)
+	}
+
+	// the first registered one wins when two segments differ only by case.
+	if lower := strings.ToLower(s); n.foldChildren[lower] == nil {
+		n.foldChildren[lower] = child
+	}
 }
 
 func (n *Node) getChild(s string) *Node {
@@ -62,19 +83,348 @@ func (n *Node) hasChild(s string) bool {
 	return n.getChild(s) != nil
 }
 
-func (n *Node) findClosestParentWildcardNode() *Node {
-	n = n.parent
-	for n != nil {
-		if n.childWildcardParameter {
+// removeChild removes a (static, named parameter or wildcard) child and recomputes the dynamic child flags.
+func (n *Node) removeChild(child *Node) {
+	if child.segment == ParamStart {
+		for i, c := range n.paramChildren {
+			if c == child {
+				n.paramChildren = append(n.paramChildren[:i], n.paramChildren[i+1:]...)
+				break
+			}
+		}
+	} else {
+		delete(n.children, child.segment)
+
+		if lower := strings.ToLower(child.segment); n.foldChildren[lower] == child {
+			delete(n.foldChildren, lower)
+			// fallback to another static child of the same case folding, if any.
+			var next *Node
+			for s, c := range n.children {
+				if strings.ToLower(s) == lower && (next == nil || s < next.segment) {
+					next = c
+				}
+			}
+
+			if next != nil {
+				n.foldChildren[lower] = next
+			}
+		}
+	}
+
+	child.parent = nil
+	n.childWildcardParameter = n.hasChild(WildcardParamStart)
+}
+
+// clone returns a deep copy of this node and its children.
+func (n *Node) clone(parent *Node) *Node {
+	c := new(Node)
+	*c = *n
+	c.parent = parent
+
+	if n.children != nil {
+		c.children = make(map[string]*Node, len(n.children))
+		for s, child := range n.children {
+			c.children[s] = child.clone(c)
+		}
+
+		c.foldChildren = make(map[string]*Node, len(n.foldChildren))
+		for lower, child := range n.foldChildren {
+			c.foldChildren[lower] = c.children[child.segment]
+		}
+	}
+
+	if n.paramChildren != nil {
+		c.paramChildren = make([]*Node, len(n.paramChildren))
+		for i, child := range n.paramChildren {
+			c.paramChildren[i] = child.clone(c)
+		}
+	}
+
+	if n.paramKeys != nil {
+		c.paramKeys = append([]string(nil), n.paramKeys...)
+	}
+
+	return c
+}
+
+// addParamChild adds a named parameter child, the constrained ones
+// are kept before the unconstrained one so they are tried first on `Trie#Search`.
+func (n *Node) addParamChild(child *Node) {
+	child.parent = n
+
+	i := len(n.paramChildren)
+	if child.paramConstraint != nil {
+		for i > 0 && n.paramChildren[i-1].paramConstraint == nil {
+			i--
+		}
+	}
+
+	n.paramChildren = append(n.paramChildren, nil)
+	copy(n.paramChildren[i+1:], n.paramChildren[i:])
+	n.paramChildren[i] = child
+}
+
+// getParamChild returns the named parameter child with the "constraintExpr", if any.
+func (n *Node) getParamChild(constraintExpr string) *Node {
+	for _, child := range n.paramChildren {
+		if child.paramConstraintExpr == constraintExpr {
+			return child
+		}
+	}
+
+	return nil
+}
+
+// getPatternChild returns the child which is responsible for a path pattern's segment,
+// i.e "users", ":id|int" or "*path".
+func (n *Node) getPatternChild(s string) *Node {
+	if s != "" {
+		switch s[0] {
+		case ParamStart[0]:
+			_, constraintExpr := splitParamConstraint(s[1:])
+			return n.getParamChild(constraintExpr)
+		case WildcardParamStart[0]:
 			return n.getChild(WildcardParamStart)
 		}
+	}
+
+	return n.getChild(s)
+}
+
+// matchParam reports whether the "value" is accepted by this named parameter node's constraint, if any.
+func (n *Node) matchParam(value string) bool {
+	return n.paramConstraint == nil || n.paramConstraint(value)
+}
+
+// search returns the end node which matches the path segments of "q",
+// starting from the segment at "start" index.
+// It tries the static child, then the named parameters and then the wildcard,
+// when a child branch dead-ends on a next segment it goes back and tries the next candidate.
+// When "fold" is true the static path segments are compared case-insensitively.
+func (n *Node) search(q string, start int, fold bool) *Node {
+	i := start
+	for i < len(q) && q[i] != pathSepB {
+		i++
+	}
+	segment := q[start:i]
+
+	if segment != WildcardParamStart {
+		child := n.getChild(segment)
+		if child != nil {
+			if found := child.searchNext(q, i, fold); found != nil {
+				return found
+			}
+		}
+
+		if fold {
+			if foldChild := n.foldChildren[strings.ToLower(segment)]; foldChild != nil && foldChild != child {
+				if found := foldChild.searchNext(q, i, fold); found != nil {
+					return found
+				}
+			}
+		}
+	}
+
+	for _, child := range n.paramChildren {
+		if !child.matchParam(segment) {
+			continue
+		}
 
-		n = n.parent
+		if found := child.searchNext(q, i, fold); found != nil {
+			return found
+		}
+	}
+
+	if n.childWildcardParameter {
+		// means that it has :param/static and *wildcard, we go trhough the :param
+		// but the next path segment is not the /static, so go back to *wildcard
+		// instead of not found.
+		//
+		// Fixes:
+		// /hello/*p
+		// /hello/:p1/static/:p2
+		// req: http://localhost:8080/hello/dsadsa/static/dsadsa => found
+		// req: http://localhost:8080/hello/dsadsa => but not found!
+		// and
+		// /second/wild/*p
+		// /second/wild/static/otherstatic/
+		// req: /second/wild/static/otherstatic/random => but not found!
+		if child := n.getChild(WildcardParamStart); child.end {
+			return child
+		}
 	}
 
 	return nil
 }
 
+// searchNext continues the search after the path segment which ends at "i".
+func (n *Node) searchNext(q string, i int, fold bool) *Node {
+	if i == len(q) {
+		if n.end {
+			return n
+		}
+
+		return nil
+	}
+
+	return n.search(q, i+1, fold)
+}
+
+// setParams walks the path segments of "q" from the root to this (found) node
+// and sets the values of its named parameters and wildcard based on the "keys", in order.
+// It returns the start index of the next path segment and the number of the parameters set so far.
+func (n *Node) setParams(q string, keys []string, params ParamsSetter) (int, int) {
+	if n.parent == nil {
+		return 1, 0
+	}
+
+	start, k := n.parent.setParams(q, keys, params)
+	if start > len(q) {
+		return start, k
+	}
+
+	i := start
+	for i < len(q) && q[i] != pathSepB {
+		i++
+	}
+
+	switch n.segment {
+	case ParamStart:
+		if k < len(keys) {
+			params.Set(keys[k], q[start:i])
+		}
+		k++
+	case WildcardParamStart:
+		if k < len(keys) {
+			params.Set(keys[k], q[start:])
+		}
+		return len(q) + 1, k + 1
+	}
+
+	return i + 1, k
+}
+
+// wrapHandler wraps the node's Handler with the middlewares of the `With` insert option, if any.
+func (n *Node) wrapHandler() {
+	if len(n.wrappers) > 0 && n.Handler != nil {
+		n.Handler = Pre(n.wrappers...).For(n.Handler)
+	}
+
+	n.wrappers = nil
+}
+
+// CanonicalPath returns the "q" path, which this node matched through a `Trie#SearchFold`,
+// with its static path segments as they were registered and its parameter values as they are.
+func (n *Node) CanonicalPath(q string) string {
+	if len(q) <= 1 {
+		return q
+	}
+
+	var b strings.Builder
+	n.writeCanonicalPath(&b, q)
+	return b.String()
+}
+
+// writeCanonicalPath writes the canonical form of the "q" path segments
+// from the root to this node to "b", see `CanonicalPath`.
+// It returns the start index of the next path segment.
+func (n *Node) writeCanonicalPath(b *strings.Builder, q string) int {
+	if n.parent == nil {
+		return 1
+	}
+
+	start := n.parent.writeCanonicalPath(b, q)
+	if start > len(q) {
+		return start
+	}
+
+	i := start
+	for i < len(q) && q[i] != pathSepB {
+		i++
+	}
+
+	b.WriteByte(pathSepB)
+	switch n.segment {
+	case ParamStart:
+		b.WriteString(q[start:i])
+	case WildcardParamStart:
+		b.WriteString(q[start:])
+		return len(q) + 1
+	default:
+		b.WriteString(n.segment)
+	}
+
+	return i + 1
+}
+
+// errStopWalk stops a `walk` early.
+var errStopWalk = errors.New("stop walk")
+
+// findTag returns the first node, in the `walk` order, which is registered with the "tag",
+// so the result is the same on every call when more than one node has that tag.
+func (n *Node) findTag(tag string) (found *Node) {
+	n.walk(func(child *Node) error {
+		if child.Tag == tag {
+			found = child
+			return errStopWalk
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// buildPath writes the path segments from the root to this node,
+// the named parameters and wildcard are replaced with their escaped "values" based on the "keys".
+// It returns the number of the parameters written so far.
+func (n *Node) buildPath(b *strings.Builder, keys []string, values map[string]string) (int, error) {
+	if n.parent == nil {
+		return 0, nil
+	}
+
+	k, err := n.parent.buildPath(b, keys, values)
+	if err != nil {
+		return k, err
+	}
+
+	b.WriteString(pathSep)
+
+	switch n.segment {
+	case ParamStart, WildcardParamStart:
+		if k >= len(keys) {
+			return k, fmt.Errorf("missing parameter key")
+		}
+
+		key := keys[k]
+		value, ok := values[key]
+		if !ok {
+			return k, fmt.Errorf("missing parameter %q", key)
+		}
+
+		if n.segment == WildcardParamStart {
+			for i, part := range strings.Split(value, pathSep) {
+				if i > 0 {
+					b.WriteString(pathSep)
+				}
+				b.WriteString(url.PathEscape(part))
+			}
+		} else {
+			if value == "" || !n.matchParam(value) {
+				return k, fmt.Errorf("invalid value %q for parameter %q", value, key)
+			}
+
+			b.WriteString(url.PathEscape(value))
+		}
+
+		k++
+	default:
+		b.WriteString(n.segment)
+	}
+
+	return k, nil
+}
+
 // NodeKeysSorter is the type definition for the sorting logic
 // that caller can pass on `GetKeys` and `Autocomplete`.
 type NodeKeysSorter = func(list []string) func(i, j int) bool
@@ -103,6 +453,10 @@ func (n *Node) Keys(sorter NodeKeysSorter) (list []string) {
 		}
 	}
 
+	for _, child := range n.paramChildren {
+		list = append(list, child.Keys(sorter)...)
+	}
+
 	if sorter != nil {
 		sort.Slice(list, sorter(list))
 	}
@@ -110,6 +464,53 @@ func (n *Node) Keys(sorter NodeKeysSorter) (list []string) {
 	return
 }
 
+// walk calls the "fn" for this node, if it's a final path, and its children,
+// the static ones sorted by their path segment, then the named parameters and then the wildcard.
+func (n *Node) walk(fn func(*Node) error) error {
+	if n.end {
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+
+	segments := make([]string, 0, len(n.children))
+	for s := range n.children {
+		if s != WildcardParamStart {
+			segments = append(segments, s)
+		}
+	}
+	sort.Strings(segments)
+
+	for _, s := range segments {
+		if err := n.children[s].walk(fn); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range n.paramChildren {
+		if err := child.walk(fn); err != nil {
+			return err
+		}
+	}
+
+	if child := n.getChild(WildcardParamStart); child != nil {
+		return child.walk(fn)
+	}
+
+	return nil
+}
+
+// Key returns the path pattern of this node, if it's a final path, see `IsEnd`.
+func (n *Node) Key() string {
+	return n.key
+}
+
+// ParamKeys returns the named parameters and wildcard keys (without : or *)
+// of this node's path pattern, if any.
+func (n *Node) ParamKeys() []string {
+	return n.paramKeys
+}
+
 // Parent returns the parent of that node, can return nil if this is the root node.
 func (n *Node) Parent() *Node {
 	return n.parent
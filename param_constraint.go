@@ -0,0 +1,155 @@
+package muxie
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ParamConstraintStart is the character, as a string, which separates a named parameter
+// from its constraint inside a path pattern, i.e "/users/:id|int".
+const ParamConstraintStart = "|"
+
+// ParamConstraint reports whether a path segment is a valid value for a named parameter.
+// See `RegisterParamConstraint` too.
+type ParamConstraint func(value string) bool
+
+// ParamConstraintMaker returns a new `ParamConstraint` based on the constraint's argument,
+// i.e the "^[a-z]+$" of the "/:name|regex(^[a-z]+$)" pattern.
+// The argument is empty when the constraint is declared without parenthesis, i.e "/:id|int".
+type ParamConstraintMaker func(arg string) (ParamConstraint, error)
+
+var (
+	paramConstraintsMu sync.RWMutex
+	paramConstraints   = map[string]ParamConstraintMaker{
+		"int":   noArgParamConstraint("int", isInt),
+		"uint":  noArgParamConstraint("uint", isUint),
+		"alpha": noArgParamConstraint("alpha", isAlpha),
+		"uuid":  noArgParamConstraint("uuid", isUUID),
+		"regex": regexParamConstraint,
+	}
+)
+
+// RegisterParamConstraint registers a custom constraint kind that can be used
+// on named parameters' patterns, i.e:
+//
+//	muxie.RegisterParamConstraint("even", func(arg string) (muxie.ParamConstraint, error) {
+//	    return func(value string) bool {
+//	        n, err := strconv.Atoi(value)
+//	        return err == nil && n%2 == 0
+//	    }, nil
+//	})
+//	mux.HandleFunc("/numbers/:n|even", evenNumberHandler)
+//
+// The built-in kinds are the "int", "uint", "alpha", "uuid" and "regex(expr)".
+// A registration with the same "kind" overrides the previous one.
+// It should be called before the `Trie#Insert` or `Mux#Handle` which uses that kind.
+func RegisterParamConstraint(kind string, maker ParamConstraintMaker) {
+	if kind == "" || maker == nil {
+		panic("muxie/RegisterParamConstraint: empty kind or maker")
+	}
+
+	paramConstraintsMu.Lock()
+	paramConstraints[kind] = maker
+	paramConstraintsMu.Unlock()
+}
+
+// parseParamConstraint returns the `ParamConstraint` of a constraint expression,
+// i.e "int" or "regex(^[a-z]+$)".
+func parseParamConstraint(expr string) (ParamConstraint, error) {
+	kind, arg := expr, ""
+	if i := strings.IndexByte(expr, '('); i > 0 && expr[len(expr)-1] == ')' {
+		kind, arg = expr[:i], expr[i+1:len(expr)-1]
+	}
+
+	paramConstraintsMu.RLock()
+	maker, ok := paramConstraints[kind]
+	paramConstraintsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown parameter constraint: %q", kind)
+	}
+
+	return maker(arg)
+}
+
+// splitParamConstraint splits a named parameter's segment, without the ":",
+// to its name and its constraint expression, if any.
+func splitParamConstraint(s string) (name string, expr string) {
+	if i := strings.Index(s, ParamConstraintStart); i != -1 {
+		return s[:i], s[i+1:]
+	}
+
+	return s, ""
+}
+
+func noArgParamConstraint(kind string, constraint ParamConstraint) ParamConstraintMaker {
+	return func(arg string) (ParamConstraint, error) {
+		if arg != "" {
+			return nil, fmt.Errorf("parameter constraint %q does not accept an argument", kind)
+		}
+
+		return constraint, nil
+	}
+}
+
+func regexParamConstraint(arg string) (ParamConstraint, error) {
+	if arg == "" {
+		return nil, fmt.Errorf("parameter constraint \"regex\" requires an expression")
+	}
+
+	re, err := regexp.Compile(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return re.MatchString, nil
+}
+
+func isInt(value string) bool {
+	_, err := strconv.ParseInt(value, 10, 64)
+	return err == nil
+}
+
+func isUint(value string) bool {
+	_, err := strconv.ParseUint(value, 10, 64)
+	return err == nil
+}
+
+func isAlpha(value string) bool {
+	if value == "" {
+		return false
+	}
+
+	for i := 0; i < len(value); i++ {
+		if c := value[i] | 0x20; c < 'a' || c > 'z' { // lower it.
+			return false
+		}
+	}
+
+	return true
+}
+
+func isUUID(value string) bool {
+	// xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+	if len(value) != 36 {
+		return false
+	}
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,85 @@
+package muxie
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestParamConstraint(t *testing.T) {
+	RegisterParamConstraint("even", func(arg string) (ParamConstraint, error) {
+		return func(value string) bool {
+			n, err := strconv.Atoi(value)
+			return err == nil && n%2 == 0
+		}, nil
+	})
+
+	tree := NewTrie()
+	tree.Insert("/users/:id|int", WithTag("user_by_id"))
+	tree.Insert("/users/*path", WithTag("users_wildcard"))
+	tree.Insert("/files/:name|regex(^[a-z]+\\.png$)", WithTag("png"))
+	tree.Insert("/v/:ver|uuid", WithTag("version"))
+	tree.Insert("/letters/:word|alpha/count", WithTag("letters_count"))
+	tree.Insert("/numbers/:n|even", WithTag("even"))
+	tree.Insert("/*anything", WithTag("root_wildcard"))
+
+	tests := []struct {
+		path        string
+		expectedTag string
+		paramKey    string
+		paramValue  string
+	}{
+		{"/users/42", "user_by_id", "id", "42"},
+		{"/users/-42", "user_by_id", "id", "-42"},
+		{"/users/kataras", "users_wildcard", "path", "kataras"},
+		{"/users/42/friends", "users_wildcard", "path", "42/friends"},
+		{"/files/logo.png", "png", "name", "logo.png"},
+		{"/files/logo.jpg", "root_wildcard", "anything", "files/logo.jpg"},
+		{"/v/9f5c1c3e-8e3e-4b3e-9c8e-3e8e4b3e9c8e", "version", "ver", "9f5c1c3e-8e3e-4b3e-9c8e-3e8e4b3e9c8e"},
+		{"/v/1.0.0", "root_wildcard", "anything", "v/1.0.0"},
+		{"/letters/muxie/count", "letters_count", "word", "muxie"},
+		{"/letters/mux1e/count", "root_wildcard", "anything", "letters/mux1e/count"},
+		{"/numbers/4", "even", "n", "4"},
+		{"/numbers/3", "root_wildcard", "anything", "numbers/3"},
+	}
+
+	for i, tt := range tests {
+		params := new(Writer)
+		n := tree.Search(tt.path, params)
+		if n == nil {
+			t.Fatalf("[%d] %s: expected node with tag: '%s' to be found", i, tt.path, tt.expectedTag)
+		}
+
+		if expected, got := tt.expectedTag, n.Tag; expected != got {
+			t.Fatalf("[%d] %s: expected tag: '%s' but got: '%s'", i, tt.path, expected, got)
+		}
+
+		if expected, got := tt.paramValue, params.Get(tt.paramKey); expected != got {
+			t.Fatalf("[%d] %s: expected param '%s' to be: '%s' but got: '%s'", i, tt.path, tt.paramKey, expected, got)
+		}
+	}
+}
+
+func TestParamConstraintMux(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc("/users/:id|int", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user " + GetParam(w, "id")))
+	})
+
+	testHandler(t, mux, http.MethodGet, "/users/42").statusCode(http.StatusOK).bodyEq("user 42")
+	testHandler(t, mux, http.MethodGet, "/users/kataras").statusCode(http.StatusNotFound)
+}
+
+func TestParamConstraintInvalid(t *testing.T) {
+	for _, pattern := range []string{"/users/:id|unknown", "/users/:id|int(5)", "/users/:id|regex([a-z)"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("%s: expected to panic", pattern)
+				}
+			}()
+
+			NewTrie().Insert(pattern)
+		}()
+	}
+}
@@ -0,0 +1,187 @@
+package muxie
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrParamMissing is the `ParamError.Err` when the route has not a parameter with that key.
+var ErrParamMissing = errors.New("missing parameter")
+
+// ParamError describes why a path parameter value cannot be converted to the requested type.
+type ParamError struct {
+	// Key is the parameter's key, i.e "id".
+	Key string
+	// Value is the raw parameter value.
+	Value string
+	// Err is the `ErrParamMissing` or the parse error, i.e the strconv.ErrSyntax.
+	Err error
+}
+
+func (e *ParamError) Error() string {
+	if e.Err == ErrParamMissing {
+		return fmt.Sprintf("muxie: %v %q", e.Err, e.Key)
+	}
+
+	return fmt.Sprintf("muxie: parameter %q: invalid value %q: %v", e.Key, e.Value, e.Err)
+}
+
+// Unwrap returns the reason of the error, so it can be checked with `errors.Is`.
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// Params is a typed view over the path parameters of a `ParamStore`, i.e:
+//
+//	id, err := muxie.ParamsOf(w).Int("id")
+//
+// Its `MustInt` method sends a 400 `Problem` to the client when the value is missing or invalid.
+type Params struct {
+	ParamStore
+	w http.ResponseWriter
+}
+
+// ParamsOf returns the `Params` of the "w" http.ResponseWriter, see `GetParam` too.
+func ParamsOf(w http.ResponseWriter) Params {
+	store := paramStoreOf(w)
+	if store == nil {
+		store = emptyParamStore{}
+	}
+
+	return Params{ParamStore: store, w: w}
+}
+
+// Lookup returns the value of the parameter and reports whether the route has a parameter with that key,
+// so a missing parameter can be separated from an empty one.
+func (p Params) Lookup(key string) (string, bool) {
+	for _, entry := range p.GetAll() {
+		if entry.Key == key {
+			return entry.Value, true
+		}
+	}
+
+	return "", false
+}
+
+func (p Params) parse(key string, parse func(value string) error) error {
+	value, ok := p.Lookup(key)
+	if !ok {
+		return &ParamError{Key: key, Err: ErrParamMissing}
+	}
+
+	if err := parse(value); err != nil {
+		if numErr, ok := err.(*strconv.NumError); ok {
+			err = numErr.Err
+		}
+
+		return &ParamError{Key: key, Value: value, Err: err}
+	}
+
+	return nil
+}
+
+// Int returns the parameter's value as int.
+func (p Params) Int(key string) (v int, err error) {
+	err = p.parse(key, func(value string) (err error) {
+		v, err = strconv.Atoi(value)
+		return
+	})
+	return
+}
+
+// Int64 returns the parameter's value as int64.
+func (p Params) Int64(key string) (v int64, err error) {
+	err = p.parse(key, func(value string) (err error) {
+		v, err = strconv.ParseInt(value, 10, 64)
+		return
+	})
+	return
+}
+
+// Uint returns the parameter's value as uint.
+func (p Params) Uint(key string) (v uint, err error) {
+	err = p.parse(key, func(value string) error {
+		n, err := strconv.ParseUint(value, 10, strconv.IntSize)
+		v = uint(n)
+		return err
+	})
+	return
+}
+
+// Float64 returns the parameter's value as float64.
+func (p Params) Float64(key string) (v float64, err error) {
+	err = p.parse(key, func(value string) (err error) {
+		v, err = strconv.ParseFloat(value, 64)
+		return
+	})
+	return
+}
+
+// Bool returns the parameter's value as bool,
+// it accepts the values of the `strconv.ParseBool`, i.e "1", "true", "0", "false".
+func (p Params) Bool(key string) (v bool, err error) {
+	err = p.parse(key, func(value string) (err error) {
+		v, err = strconv.ParseBool(value)
+		return
+	})
+	return
+}
+
+// Time returns the parameter's value as time.Time based on the "layout", i.e "2006-01-02".
+func (p Params) Time(key, layout string) (v time.Time, err error) {
+	err = p.parse(key, func(value string) (err error) {
+		v, err = time.Parse(layout, value)
+		return
+	})
+	return
+}
+
+// Duration returns the parameter's value as time.Duration, i.e "1h30m".
+func (p Params) Duration(key string) (v time.Duration, err error) {
+	err = p.parse(key, func(value string) (err error) {
+		v, err = time.ParseDuration(value)
+		return
+	})
+	return
+}
+
+// UUID returns the parameter's value when it is a valid UUID, i.e "9f5c1c3e-8e3e-4b3e-9c8e-3e8e4b3e9c8e".
+func (p Params) UUID(key string) (v string, err error) {
+	err = p.parse(key, func(value string) error {
+		if !isUUID(value) {
+			return errors.New("not a UUID")
+		}
+
+		v = value
+		return nil
+	})
+	return
+}
+
+// MustInt is like `Int` but on failure it sends a 400 `Problem` to the client and reports false,
+// the handler should return immediately, i.e:
+//
+//	id, ok := muxie.ParamsOf(w).MustInt("id")
+//	if !ok {
+//	    return
+//	}
+func (p Params) MustInt(key string) (int, bool) {
+	v, err := p.Int(key)
+	return v, p.must(err)
+}
+
+// must sends the "err" as a 400 `Problem` to the client, if not nil, and reports whether the "err" was nil.
+func (p Params) must(err error) bool {
+	if err == nil {
+		return true
+	}
+
+	if p.w != nil {
+		NewProblem(http.StatusBadRequest, err.Error()).write(p.w)
+	}
+
+	return false
+}
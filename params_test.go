@@ -0,0 +1,92 @@
+package muxie
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParams(t *testing.T) {
+	pw := new(Writer)
+	pw.Set("id", "42")
+	pw.Set("neg", "-7")
+	pw.Set("price", "9.99")
+	pw.Set("ok", "true")
+	pw.Set("day", "2020-01-02")
+	pw.Set("ttl", "1h30m")
+	pw.Set("ver", "9f5c1c3e-8e3e-4b3e-9c8e-3e8e4b3e9c8e")
+	pw.Set("empty", "")
+
+	p := ParamsOf(pw)
+
+	if v, err := p.Int("id"); err != nil || v != 42 {
+		t.Fatalf("Int: expected 42 but got: %d (%v)", v, err)
+	}
+	if v, err := p.Int64("neg"); err != nil || v != -7 {
+		t.Fatalf("Int64: expected -7 but got: %d (%v)", v, err)
+	}
+	if v, err := p.Uint("id"); err != nil || v != 42 {
+		t.Fatalf("Uint: expected 42 but got: %d (%v)", v, err)
+	}
+	if v, err := p.Float64("price"); err != nil || v != 9.99 {
+		t.Fatalf("Float64: expected 9.99 but got: %v (%v)", v, err)
+	}
+	if v, err := p.Bool("ok"); err != nil || !v {
+		t.Fatalf("Bool: expected true but got: %v (%v)", v, err)
+	}
+	if v, err := p.Time("day", "2006-01-02"); err != nil || !v.Equal(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Time: expected 2020-01-02 but got: %v (%v)", v, err)
+	}
+	if v, err := p.Duration("ttl"); err != nil || v != 90*time.Minute {
+		t.Fatalf("Duration: expected 1h30m but got: %v (%v)", v, err)
+	}
+	if v, err := p.UUID("ver"); err != nil || v != "9f5c1c3e-8e3e-4b3e-9c8e-3e8e4b3e9c8e" {
+		t.Fatalf("UUID: expected the uuid but got: %s (%v)", v, err)
+	}
+
+	if v, ok := p.Lookup("empty"); !ok || v != "" {
+		t.Fatalf("expected the empty parameter to exist")
+	}
+	if _, ok := p.Lookup("missing"); ok {
+		t.Fatalf("expected the missing parameter to not exist")
+	}
+
+	_, err := p.Int("missing")
+	if !errors.Is(err, ErrParamMissing) {
+		t.Fatalf("expected ErrParamMissing but got: %v", err)
+	}
+
+	_, err = p.Uint("neg")
+	var paramErr *ParamError
+	if !errors.As(err, &paramErr) || paramErr.Key != "neg" || paramErr.Value != "-7" || !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatalf("expected a syntax ParamError for 'neg' but got: %v", err)
+	}
+
+	if _, err = p.UUID("id"); err == nil {
+		t.Fatalf("expected an error for an invalid UUID")
+	}
+
+	if _, err = ParamsOf(nil).Int("id"); !errors.Is(err, ErrParamMissing) {
+		t.Fatalf("expected ErrParamMissing on a response writer without parameters but got: %v", err)
+	}
+}
+
+func TestParamsMustInt(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := ParamsOf(w).MustInt("id")
+		if !ok {
+			return
+		}
+
+		fmt.Fprintf(w, "user %d", id)
+	})
+
+	testHandler(t, mux, http.MethodGet, "/users/42").statusCode(http.StatusOK).bodyEq("user 42")
+	testHandler(t, mux, http.MethodGet, "/users/kataras").statusCode(http.StatusBadRequest).
+		headerEq("Content-Type", ProblemContentType).
+		bodyEq(`{"title":"Bad Request","status":400,"detail":"muxie: parameter \"id\": invalid value \"kataras\": invalid syntax"}`)
+}
@@ -1,6 +1,11 @@
 package muxie
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
 
 // ParamStore should be completed by http.ResponseWriter to support dynamic path parameters.
 // See the `Writer` type for more.
@@ -19,9 +24,11 @@ type ParamStore interface {
 // then the `GetParam("name")` will return the value of "kataras".
 // If not associated value with that key is found then it will return an empty string.
 //
-// The function will do its job only if the given "w" http.ResponseWriter interface is a `ParamStore`.
+// The function will do its job only if the given "w" http.ResponseWriter interface is a `ParamStore`
+// or it wraps one through an `Unwrap() http.ResponseWriter` method.
+// See `ParamsFromContext` too.
 func GetParam(w http.ResponseWriter, key string) string {
-	if store, ok := w.(ParamStore); ok {
+	if store := paramStoreOf(w); store != nil {
 		return store.Get(key)
 	}
 
@@ -30,9 +37,10 @@ func GetParam(w http.ResponseWriter, key string) string {
 
 // GetParams returns all the available parameters based on the "w" http.ResponseWriter which should be a ParamStore.
 //
-// The function will do its job only if the given "w" http.ResponseWriter interface is a `ParamStore`.
+// The function will do its job only if the given "w" http.ResponseWriter interface is a `ParamStore`
+// or it wraps one through an `Unwrap() http.ResponseWriter` method.
 func GetParams(w http.ResponseWriter) []ParamEntry {
-	if store, ok := w.(ParamStore); ok {
+	if store := paramStoreOf(w); store != nil {
 		return store.GetAll()
 	}
 
@@ -43,7 +51,7 @@ func GetParams(w http.ResponseWriter) []ParamEntry {
 // This is not commonly used by the end-developers,
 // unless sharing values(string messages only) between handlers is absolutely necessary.
 func SetParam(w http.ResponseWriter, key, value string) bool {
-	if store, ok := w.(ParamStore); ok {
+	if store := paramStoreOf(w); store != nil {
 		store.Set(key, value)
 		return true
 	}
@@ -51,6 +59,77 @@ func SetParam(w http.ResponseWriter, key, value string) bool {
 	return false
 }
 
+// paramStoreOf returns the "w" as `ParamStore`, if it is not then it walks its
+// `Unwrap() http.ResponseWriter` chain (the convention of the response writers that wrap another one)
+// until a ParamStore is found, otherwise it returns nil.
+func paramStoreOf(w http.ResponseWriter) ParamStore {
+	for w != nil {
+		if store, ok := w.(ParamStore); ok {
+			return store
+		}
+
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+
+		w = u.Unwrap()
+	}
+
+	return nil
+}
+
+type paramsContextKey struct{}
+
+// ParamsFromContext returns the path parameters of the route that the `Mux` matched,
+// through the request's context, i.e `muxie.ParamsFromContext(r.Context()).Get("id")`,
+// the `Mux#ContextParams` should be true.
+// Useful when the http.ResponseWriter is wrapped by a middleware without an `Unwrap` method.
+//
+// The returned ParamStore is never nil, it is empty when the route has not any path parameters.
+// It is a copy of the parameters, so it can be used after the handler returns, i.e by a goroutine,
+// and its `Set` is a no-op.
+func ParamsFromContext(ctx context.Context) ParamStore {
+	if store, ok := ctx.Value(paramsContextKey{}).(ParamStore); ok {
+		return store
+	}
+
+	return emptyParamStore{}
+}
+
+// emptyParamStore is a `ParamStore` without parameters, it ignores the new ones.
+type emptyParamStore struct{}
+
+func (emptyParamStore) Set(string, string)   {}
+func (emptyParamStore) Get(string) string    { return "" }
+func (emptyParamStore) GetAll() []ParamEntry { return nil }
+
+// paramsSnapshot is an immutable copy of the parameters of a `Writer`, see `ParamsFromContext`.
+type paramsSnapshot []ParamEntry
+
+// newParamsSnapshot returns a copy of the "parent" and the "params" parameters, in that order.
+func newParamsSnapshot(parent, params []ParamEntry) paramsSnapshot {
+	snapshot := make(paramsSnapshot, 0, len(parent)+len(params))
+	snapshot = append(snapshot, parent...)
+	return append(snapshot, params...)
+}
+
+func (paramsSnapshot) Set(string, string) {}
+
+func (params paramsSnapshot) Get(key string) string {
+	for _, p := range params {
+		if p.Key == key {
+			return p.Value
+		}
+	}
+
+	return ""
+}
+
+func (params paramsSnapshot) GetAll() []ParamEntry {
+	return params
+}
+
 // ParamEntry holds the Key and the Value of a named path parameter.
 type ParamEntry struct {
 	Key   string
@@ -58,11 +137,23 @@ type ParamEntry struct {
 }
 
 // Writer is the muxie's specific ResponseWriter to hold the path parameters.
-// Usage: use this to cast a handler's `http.ResponseWriter` and pass it as an embedded parameter to custom response writer
+// Usage: cast a handler's `http.ResponseWriter` to a `ParamStore` and pass it as an embedded parameter to custom response writer
 // that will be passed to the next handler in the chain.
+//
+// The `Mux` passes it to the handlers as a value which implements the same optional interfaces
+// (http.Flusher, http.Hijacker, http.Pusher and io.ReaderFrom) as the server's response writer,
+// therefore a `w.(*muxie.Writer)` type assertion inside a handler fails.
+// Use the `GetParam` and `GetParams` functions (or cast to a `ParamStore`) to read the parameters,
+// the `ParamsFromContext` to read them from the request's context, see `Mux#ContextParams`,
+// and the `Unwrap` method, which is used by the `http.NewResponseController` as well, to access the underlying response writer.
 type Writer struct {
 	http.ResponseWriter
 	params []ParamEntry
+	// parent is the ParamStore of the response writer that this Writer wraps, if any,
+	// i.e the Writer of a Mux which mounts this Mux under a prefix with parameters, see `Mux#Mount`.
+	parent ParamStore
+	// unescape is set by the `Mux` while searching, see `Mux#UnescapePathValues`.
+	unescape bool
 }
 
 var _ ParamStore = (*Writer)(nil)
@@ -71,6 +162,12 @@ var _ ParamStore = (*Writer)(nil)
 // These are decoupled because end-developers may want to use the trie to design a new Mux of their own
 // or to store different kind of data inside it.
 func (pw *Writer) Set(key, value string) {
+	if pw.unescape && strings.IndexByte(value, '%') != -1 {
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			value = unescaped
+		}
+	}
+
 	if ln := len(pw.params); cap(pw.params) > ln {
 		pw.params = pw.params[:ln+1]
 		p := &pw.params[ln]
@@ -86,6 +183,7 @@ func (pw *Writer) Set(key, value string) {
 }
 
 // Get returns the value of the associated parameter based on its key/name.
+// It falls back to the parameters of the wrapped response writer, if it is a `ParamStore`.
 func (pw *Writer) Get(key string) string {
 	n := len(pw.params)
 	for i := 0; i < n; i++ {
@@ -94,15 +192,33 @@ func (pw *Writer) Get(key string) string {
 		}
 	}
 
+	if pw.parent != nil {
+		return pw.parent.Get(key)
+	}
+
 	return ""
 }
 
-// GetAll returns all the path parameters keys-values.
+// GetAll returns all the path parameters keys-values,
+// the ones of the wrapped response writer, if it is a `ParamStore`, come first.
 func (pw *Writer) GetAll() []ParamEntry {
+	if pw.parent != nil {
+		if parent := pw.parent.GetAll(); len(parent) > 0 {
+			return append(parent[:len(parent):len(parent)], pw.params...)
+		}
+	}
+
 	return pw.params
 }
 
+// Unwrap returns the underlying http.ResponseWriter.
+func (pw *Writer) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
+
 func (pw *Writer) reset(w http.ResponseWriter) {
 	pw.ResponseWriter = w
+	pw.parent = paramStoreOf(w)
 	pw.params = pw.params[0:0]
+	pw.unescape = false
 }
@@ -0,0 +1,165 @@
+package muxie
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// The writer{F,H,P,R} types are the `Writer` when its underlying http.ResponseWriter
+// implements the http.Flusher(F), http.Hijacker(H), http.Pusher(P) and io.ReaderFrom(R) optional interfaces,
+// so a handler can type-assert exactly the ones that the server's response writer supports.
+// They hold just the *Writer, so converting them to http.ResponseWriter does not allocate.
+
+const (
+	optFlusher = 1 << iota
+	optHijacker
+	optPusher
+	optReaderFrom
+)
+
+// optional returns the "pw" as a type that implements the same optional interfaces as its underlying http.ResponseWriter.
+func (pw *Writer) optional() http.ResponseWriter {
+	var flags int
+	if _, ok := pw.ResponseWriter.(http.Flusher); ok {
+		flags |= optFlusher
+	}
+	if _, ok := pw.ResponseWriter.(http.Hijacker); ok {
+		flags |= optHijacker
+	}
+	if _, ok := pw.ResponseWriter.(http.Pusher); ok {
+		flags |= optPusher
+	}
+	if _, ok := pw.ResponseWriter.(io.ReaderFrom); ok {
+		flags |= optReaderFrom
+	}
+
+	switch flags {
+	case optFlusher:
+		return writerF{pw}
+	case optHijacker:
+		return writerH{pw}
+	case optFlusher | optHijacker:
+		return writerFH{pw}
+	case optPusher:
+		return writerP{pw}
+	case optFlusher | optPusher:
+		return writerFP{pw}
+	case optHijacker | optPusher:
+		return writerHP{pw}
+	case optFlusher | optHijacker | optPusher:
+		return writerFHP{pw}
+	case optReaderFrom:
+		return writerR{pw}
+	case optFlusher | optReaderFrom:
+		return writerFR{pw}
+	case optHijacker | optReaderFrom:
+		return writerHR{pw}
+	case optFlusher | optHijacker | optReaderFrom:
+		return writerFHR{pw}
+	case optPusher | optReaderFrom:
+		return writerPR{pw}
+	case optFlusher | optPusher | optReaderFrom:
+		return writerFPR{pw}
+	case optHijacker | optPusher | optReaderFrom:
+		return writerHPR{pw}
+	case optFlusher | optHijacker | optPusher | optReaderFrom:
+		return writerFHPR{pw}
+	default:
+		return pw
+	}
+}
+
+func (pw *Writer) flush() {
+	pw.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (pw *Writer) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return pw.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+func (pw *Writer) push(target string, opts *http.PushOptions) error {
+	return pw.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+func (pw *Writer) readFrom(src io.Reader) (int64, error) {
+	return pw.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+}
+
+type writerF struct{ *Writer }
+
+func (w writerF) Flush() { w.flush() }
+
+type writerH struct{ *Writer }
+
+func (w writerH) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+type writerFH struct{ *Writer }
+
+func (w writerFH) Flush()                                       { w.flush() }
+func (w writerFH) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+type writerP struct{ *Writer }
+
+func (w writerP) Push(target string, opts *http.PushOptions) error { return w.push(target, opts) }
+
+type writerFP struct{ *Writer }
+
+func (w writerFP) Flush()                                           { w.flush() }
+func (w writerFP) Push(target string, opts *http.PushOptions) error { return w.push(target, opts) }
+
+type writerHP struct{ *Writer }
+
+func (w writerHP) Hijack() (net.Conn, *bufio.ReadWriter, error)     { return w.hijack() }
+func (w writerHP) Push(target string, opts *http.PushOptions) error { return w.push(target, opts) }
+
+type writerFHP struct{ *Writer }
+
+func (w writerFHP) Flush()                                           { w.flush() }
+func (w writerFHP) Hijack() (net.Conn, *bufio.ReadWriter, error)     { return w.hijack() }
+func (w writerFHP) Push(target string, opts *http.PushOptions) error { return w.push(target, opts) }
+
+type writerR struct{ *Writer }
+
+func (w writerR) ReadFrom(src io.Reader) (int64, error) { return w.readFrom(src) }
+
+type writerFR struct{ *Writer }
+
+func (w writerFR) Flush()                                { w.flush() }
+func (w writerFR) ReadFrom(src io.Reader) (int64, error) { return w.readFrom(src) }
+
+type writerHR struct{ *Writer }
+
+func (w writerHR) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+func (w writerHR) ReadFrom(src io.Reader) (int64, error)        { return w.readFrom(src) }
+
+type writerFHR struct{ *Writer }
+
+func (w writerFHR) Flush()                                       { w.flush() }
+func (w writerFHR) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+func (w writerFHR) ReadFrom(src io.Reader) (int64, error)        { return w.readFrom(src) }
+
+type writerPR struct{ *Writer }
+
+func (w writerPR) Push(target string, opts *http.PushOptions) error { return w.push(target, opts) }
+func (w writerPR) ReadFrom(src io.Reader) (int64, error)            { return w.readFrom(src) }
+
+type writerFPR struct{ *Writer }
+
+func (w writerFPR) Flush()                                           { w.flush() }
+func (w writerFPR) Push(target string, opts *http.PushOptions) error { return w.push(target, opts) }
+func (w writerFPR) ReadFrom(src io.Reader) (int64, error)            { return w.readFrom(src) }
+
+type writerHPR struct{ *Writer }
+
+func (w writerHPR) Hijack() (net.Conn, *bufio.ReadWriter, error)     { return w.hijack() }
+func (w writerHPR) Push(target string, opts *http.PushOptions) error { return w.push(target, opts) }
+func (w writerHPR) ReadFrom(src io.Reader) (int64, error)            { return w.readFrom(src) }
+
+type writerFHPR struct{ *Writer }
+
+func (w writerFHPR) Flush()                                           { w.flush() }
+func (w writerFHPR) Hijack() (net.Conn, *bufio.ReadWriter, error)     { return w.hijack() }
+func (w writerFHPR) Push(target string, opts *http.PushOptions) error { return w.push(target, opts) }
+func (w writerFHPR) ReadFrom(src io.Reader) (int64, error)            { return w.readFrom(src) }
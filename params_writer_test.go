@@ -1,8 +1,13 @@
 package muxie
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -16,3 +21,130 @@ func TestGetParam(t *testing.T) {
 
 	testHandler(t, mux, http.MethodGet, "/hello/kataras").bodyEq("Hello kataras")
 }
+
+func TestPathValue(t *testing.T) {
+	mux := NewMux()
+	mux.PathValues = true
+
+	mux.HandleFunc("GET /files/{owner}/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s:%s:%s", r.PathValue("owner"), r.PathValue("path"), GetParam(w, "path"))
+	})
+
+	testHandler(t, mux, http.MethodGet, "/files/kataras/docs/a.pdf").bodyEq("kataras:docs/a.pdf:docs/a.pdf")
+}
+
+type unwrapResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *unwrapResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type noUnwrapResponseWriter struct {
+	http.ResponseWriter
+}
+
+func TestParamsFromContext(t *testing.T) {
+	mux := NewMux()
+	mux.ContextParams = true
+	mux.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("unwrap") == "true" {
+				w = &unwrapResponseWriter{w}
+			} else {
+				w = &noUnwrapResponseWriter{w}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	mux.HandleFunc("/hello/:name", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s:%s", GetParam(w, "name"), ParamsFromContext(r.Context()).Get("name"))
+	})
+	mux.HandleFunc("/about", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%d", len(ParamsFromContext(r.Context()).GetAll()))
+	})
+
+	testHandler(t, mux, http.MethodGet, "/hello/kataras?unwrap=true").bodyEq("kataras:kataras")
+	testHandler(t, mux, http.MethodGet, "/hello/kataras").bodyEq(":kataras")
+	testHandler(t, mux, http.MethodGet, "/about").bodyEq("0")
+}
+
+func TestParamsFromContextAfterHandler(t *testing.T) {
+	mux := NewMux()
+	mux.ContextParams = true
+
+	contexts := make(chan context.Context, 1)
+	mux.HandleFunc("/first/:name", func(w http.ResponseWriter, r *http.Request) {
+		contexts <- r.Context()
+	})
+	mux.HandleFunc("/second/:name", func(w http.ResponseWriter, r *http.Request) {})
+
+	testHandler(t, mux, http.MethodGet, "/first/kataras")
+	ctx := <-contexts
+
+	// the pooled writer of the first request serves the next ones.
+	for i := 0; i < 10; i++ {
+		testHandler(t, mux, http.MethodGet, "/second/other")
+	}
+
+	params := ParamsFromContext(ctx)
+	if got := params.Get("name"); got != "kataras" {
+		t.Fatalf("expected the parameter of the first request: 'kataras' but got: '%s'", got)
+	}
+
+	params.Set("name", "changed")
+	if got := params.GetAll(); len(got) != 1 || got[0].Value != "kataras" {
+		t.Fatalf("expected the parameters to be immutable but got: %v", got)
+	}
+}
+
+type hijackPushResponseWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w hijackPushResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, http.ErrNotSupported
+}
+
+func (w hijackPushResponseWriter) Push(string, *http.PushOptions) error {
+	return http.ErrNotSupported
+}
+
+func TestWriterOptionalInterfaces(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc("/stream/:id", func(w http.ResponseWriter, r *http.Request) {
+		_, flusher := w.(http.Flusher)
+		_, hijacker := w.(http.Hijacker)
+		_, pusher := w.(http.Pusher)
+		_, readerFrom := w.(io.ReaderFrom)
+
+		fmt.Fprintf(w, "%s:%v:%v:%v:%v", GetParam(w, "id"), flusher, hijacker, pusher, readerFrom)
+
+		if err := http.NewResponseController(w).Flush(); err != nil && flusher {
+			t.Fatalf("expected flush to succeed but got: %v", err)
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream/1", nil))
+	if expected, got := "1:true:false:false:false", rec.Body.String(); expected != got {
+		t.Fatalf("expected body: '%s' but got: '%s'", expected, got)
+	}
+	if !rec.Flushed {
+		t.Fatalf("expected the response recorder to be flushed")
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(hijackPushResponseWriter{rec}, httptest.NewRequest(http.MethodGet, "/stream/2", nil))
+	if expected, got := "2:true:true:true:false", rec.Body.String(); expected != got {
+		t.Fatalf("expected body: '%s' but got: '%s'", expected, got)
+	}
+
+	pw := &Writer{ResponseWriter: hijackPushResponseWriter{httptest.NewRecorder()}}
+	if allocs := testing.AllocsPerRun(100, func() { _ = pw.optional() }); allocs != 0 {
+		t.Fatalf("expected zero allocations but got: %v", allocs)
+	}
+}
@@ -0,0 +1,100 @@
+package muxie
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ProblemContentType is the content type of the `Problem` responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 "problem details" response body,
+// it is sent to the client as JSON with the `ProblemContentType`.
+//
+// It implements the `http.Handler` so it can be used as a route's handler as well,
+// i.e `mux.NotFound(muxie.NewProblem(http.StatusNotFound, "no such page"))`.
+type Problem struct {
+	// Type is a URI which identifies the problem type, empty means "about:blank".
+	Type string `json:"type,omitempty"`
+	// Title is a short summary of the problem type, i.e the http.StatusText of the Status.
+	Title string `json:"title"`
+	// Status is the HTTP status code of the response.
+	Status int `json:"status"`
+	// Detail is a human-readable explanation specific to this occurrence of the problem.
+	Detail string `json:"detail,omitempty"`
+	// Instance is a URI which identifies the specific occurrence of the problem.
+	Instance string `json:"instance,omitempty"`
+	// Errors are the invalid fields of the request, if any, see `ValidationErrors`.
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem returns a new `Problem` of the "status" code,
+// its Title is the http.StatusText of the "status".
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Error implements the error interface, it returns the Title and the Detail of the problem.
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+
+	return p.Title + ": " + p.Detail
+}
+
+// ServeHTTP sends the problem to the client.
+func (p *Problem) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.write(w)
+}
+
+func (p *Problem) write(w http.ResponseWriter) {
+	status := p.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// ProblemOf returns the `Problem` of the "err",
+// it is the "err" itself when it is a *Problem or the result of its `Problem() *Problem` method, if any,
+// i.e the `NegotiationError`. Otherwise it is a 500 Problem without details, so internal errors are not exposed.
+func ProblemOf(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+
+	var pe interface{ Problem() *Problem }
+	if errors.As(err, &pe) {
+		return pe.Problem()
+	}
+
+	return NewProblem(http.StatusInternalServerError, "")
+}
+
+// WriteError sends the "err" to the client as a `Problem`, see `ProblemOf`.
+// It can be used to render the errors of the `Bind` and `Dispatch`, i.e:
+//
+//	if err := muxie.Bind(r, negotiator, &user); err != nil {
+//	    muxie.WriteError(w, err)
+//	    return
+//	}
+func WriteError(w http.ResponseWriter, err error) {
+	ProblemOf(err).write(w)
+}
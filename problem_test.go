@@ -0,0 +1,48 @@
+package muxie
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProblem(t *testing.T) {
+	mux := NewMux()
+	mux.NotFound(NewProblem(http.StatusNotFound, "no such page"))
+
+	testHandler(t, mux, http.MethodGet, "/missing").statusCode(http.StatusNotFound).
+		headerEq("Content-Type", ProblemContentType).
+		bodyEq(`{"title":"Not Found","status":404,"detail":"no such page"}`)
+
+	if expected, got := "Not Found: no such page", NewProblem(http.StatusNotFound, "no such page").Error(); expected != got {
+		t.Fatalf("expected error: '%s' but got: '%s'", expected, got)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		err            error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{NewProblem(http.StatusConflict, "user exists"), http.StatusConflict, `{"title":"Conflict","status":409,"detail":"user exists"}`},
+		{fmt.Errorf("bind: %w", &NegotiationError{MediaType: "text/plain", Offers: []string{"application/json"}, Err: ErrUnsupportedMediaType}),
+			http.StatusUnsupportedMediaType, `{"title":"Unsupported Media Type","status":415,"detail":"unsupported media type \"text/plain\", supported: application/json"}`},
+		{errors.New("database is down"), http.StatusInternalServerError, `{"title":"Internal Server Error","status":500}`},
+	}
+
+	for i, tt := range tests {
+		w := httptest.NewRecorder()
+		WriteError(w, tt.err)
+
+		if w.Code != tt.expectedStatus {
+			t.Fatalf("[%d] expected status code: %d but got: %d", i, tt.expectedStatus, w.Code)
+		}
+
+		if got := w.Body.String(); got != tt.expectedBody {
+			t.Fatalf("[%d] expected body: '%s' but got: '%s'", i, tt.expectedBody, got)
+		}
+	}
+}
@@ -0,0 +1,475 @@
+package muxie
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Form implements the `Binder` interface.
+	// It binds the url-encoded form data of the request body to a struct value (ptr),
+	// based on its fields' `form:"name"` struct tags.
+	//
+	// Usage:
+	// muxie.Bind(r, muxie.Form, &myStructValue)
+	//
+	// Nested structs are bound through the "parent.child" keys, i.e `form:"address"` and `form:"city"`
+	// fields are bound to the "address.city" key, slice fields to all the values of their key.
+	// The time.Time fields are parsed as RFC3339, unless they declare a layout, i.e `form:"date,layout=2006-01-02"`.
+	// The fields can also implement the `encoding.TextUnmarshaler` to parse their value.
+	Form Binder = &formBinder{}
+
+	// Multipart implements the `Binder` interface.
+	// It binds the multipart form data of the request body, including the files,
+	// to a struct value (ptr) like the `Form` does.
+	// The file fields should be a *multipart.FileHeader or a []*multipart.FileHeader.
+	//
+	// Usage:
+	// muxie.Bind(r, muxie.Multipart, &myStructValue)
+	Multipart Binder = &multipartBinder{MaxMemory: 32 << 20}
+
+	// Query implements the `Binder` interface.
+	// It binds the URL query to a struct value (ptr), like the `Form` does,
+	// based on its fields' `query:"name"` struct tags.
+	//
+	// Usage:
+	// muxie.Bind(r, muxie.Query, &myStructValue)
+	Query Binder = &valuesBinder{tag: "query", values: func(r *http.Request) url.Values { return r.URL.Query() }}
+
+	// Path implements the `Binder` interface.
+	// It binds the path parameters of the route to a struct value (ptr), like the `Form` does,
+	// based on its fields' `path:"name"` struct tags, see `ParamsFromContext`.
+	// The `Mux#ContextParams` should be true.
+	//
+	// Usage:
+	// muxie.Bind(r, muxie.Path, &myStructValue)
+	Path Binder = &valuesBinder{tag: "path", values: pathValues}
+)
+
+// Combine returns a `Binder` which binds a request through all the "binders", in order,
+// so a single struct value can be filled by the path parameters, the URL query and the request body, i.e:
+//
+//	type updateUserRequest struct {
+//	    ID     int    `path:"id"`
+//	    Notify bool   `query:"notify"`
+//	    Name   string `json:"name"`
+//	}
+//	muxie.Bind(r, muxie.Combine(muxie.Path, muxie.Query, muxie.JSON), &req)
+//
+// The binders which read the request body, i.e the `JSON`, `XML`, `Form`, `Multipart` and a `Negotiator`,
+// are skipped when the request has no body, so the above binds a "GET /users/42" as well.
+// To validate the value, the Combine should be wrapped with the `Validated`, i.e `muxie.Validated(muxie.Combine(...))`.
+// It stops on the first error.
+func Combine(binders ...Binder) Binder {
+	return combinedBinder(binders)
+}
+
+type combinedBinder []Binder
+
+func (binders combinedBinder) Bind(r *http.Request, v interface{}) error {
+	emptyBody := isEmptyBody(r)
+	for _, b := range binders {
+		if emptyBody && bindsBody(b) {
+			continue
+		}
+
+		if err := b.Bind(r, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// bindsBody reports whether the "b" Binder reads the request body, see `Combine`.
+func bindsBody(b Binder) bool {
+	bb, ok := b.(interface{ bindsBody() bool })
+	return ok && bb.bindsBody()
+}
+
+// BindError describes why a request value cannot be bound to a struct field.
+// It can be sent to the client through `WriteError`, as 400 `Problem`.
+type BindError struct {
+	// Field is the key of the value, i.e "address.city", it may be empty when the decoder does not report it.
+	Field string
+	// Value is the raw request value or, for JSON, its kind, i.e "string".
+	Value string
+	// Err is the parse error, i.e the strconv.ErrSyntax, or the expected type.
+	Err error
+}
+
+func (e *BindError) Error() string {
+	return "muxie: " + e.message()
+}
+
+func (e *BindError) message() string {
+	if e.Field == "" {
+		return fmt.Sprintf("invalid value %q: %v", e.Value, e.Err)
+	}
+
+	return fmt.Sprintf("field %q: invalid value %q: %v", e.Field, e.Value, e.Err)
+}
+
+// Unwrap returns the reason of the error, so it can be checked with `errors.Is`.
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// Problem returns the 400 `Problem` of the error, see `WriteError`.
+func (e *BindError) Problem() *Problem {
+	return NewProblem(http.StatusBadRequest, e.message())
+}
+
+type formBinder struct{}
+
+var _ Binder = (*formBinder)(nil)
+
+func (p *formBinder) bindsBody() bool { return true }
+
+func (p *formBinder) Bind(r *http.Request, v interface{}) error {
+	if err := checkBindTarget(v, true); err != nil {
+		return err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return formError(r, err)
+	}
+
+	return decodeValues(r.PostForm, nil, "form", v)
+}
+
+type multipartBinder struct {
+	// MaxMemory is the maximum bytes of the files which are stored in memory, the rest are stored on disk.
+	MaxMemory int64
+}
+
+var _ Binder = (*multipartBinder)(nil)
+
+func (p *multipartBinder) bindsBody() bool { return true }
+
+func (p *multipartBinder) Bind(r *http.Request, v interface{}) error {
+	if err := checkBindTarget(v, true); err != nil {
+		return err
+	}
+
+	if err := r.ParseMultipartForm(p.MaxMemory); err != nil {
+		return formError(r, err)
+	}
+
+	return decodeValues(r.MultipartForm.Value, r.MultipartForm.File, "form", v)
+}
+
+type valuesBinder struct {
+	tag    string
+	values func(*http.Request) url.Values
+}
+
+var _ Binder = (*valuesBinder)(nil)
+
+func (p *valuesBinder) Bind(r *http.Request, v interface{}) error {
+	return decodeValues(p.values(r), nil, p.tag, v)
+}
+
+// formError converts a form parse error to a `NegotiationError` of `ErrUnsupportedMediaType`
+// when the request is not a multipart one, or to a client error like the `bodyError` does.
+func formError(r *http.Request, err error) error {
+	if errors.Is(err, http.ErrNotMultipart) {
+		return &NegotiationError{MediaType: r.Header.Get("Content-Type"), Offers: []string{"multipart/form-data"}, Err: ErrUnsupportedMediaType}
+	}
+
+	return bodyError(err)
+}
+
+func pathValues(r *http.Request) url.Values {
+	params := ParamsFromContext(r.Context()).GetAll()
+	values := make(url.Values, len(params))
+	for _, p := range params {
+		values[p.Key] = append(values[p.Key], p.Value)
+	}
+
+	return values
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType     = reflect.TypeOf([]*multipart.FileHeader(nil))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// decodeValues decodes the "values" and the "files" to the struct value which the "ptr" points to,
+// the keys are the values of the fields' "tag" struct tag or their names.
+func decodeValues(values url.Values, files map[string][]*multipart.FileHeader, tag string, ptr interface{}) error {
+	if err := checkBindTarget(ptr, true); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(ptr)
+	d := &valuesDecoder{values: values, files: files, tag: tag}
+	return d.decodeStruct(v.Elem(), "")
+}
+
+type valuesDecoder struct {
+	values url.Values
+	files  map[string][]*multipart.FileHeader
+	tag    string
+}
+
+func (d *valuesDecoder) decodeStruct(v reflect.Value, prefix string) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts := parseFieldTag(field.Tag.Get(d.tag))
+		if name == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+
+		// the fields of an embedded struct are bound as they were fields of its parent.
+		if field.Anonymous && name == "" {
+			if ft := indirectType(field.Type); ft.Kind() == reflect.Struct && !isLeafType(ft) {
+				if field.Type.Kind() == reflect.Ptr {
+					if !field.IsExported() || !d.hasPrefix(prefix) {
+						continue
+					}
+
+					if fv.IsNil() {
+						fv.Set(reflect.New(ft))
+					}
+					fv = fv.Elem()
+				}
+
+				if err := d.decodeStruct(fv, prefix); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		if err := d.decodeField(fv, prefix+name, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *valuesDecoder) decodeField(v reflect.Value, key string, opts string) error {
+	switch t := v.Type(); {
+	case t == fileHeaderType:
+		if files := d.files[key]; len(files) > 0 {
+			v.Set(reflect.ValueOf(files[0]))
+		}
+		return nil
+	case t == fileHeadersType:
+		if files := d.files[key]; len(files) > 0 {
+			v.Set(reflect.ValueOf(files))
+		}
+		return nil
+	case t.Kind() == reflect.Slice && isLeafType(t.Elem()):
+		values := d.values[key]
+		if len(values) == 0 {
+			return nil
+		}
+
+		slice := reflect.MakeSlice(t, len(values), len(values))
+		for i, value := range values {
+			if err := setFieldValue(slice.Index(i), value, opts); err != nil {
+				return &BindError{Field: key, Value: value, Err: err}
+			}
+		}
+		v.Set(slice)
+		return nil
+	case isLeafType(t):
+		values := d.values[key]
+		if len(values) == 0 {
+			return nil
+		}
+
+		if err := setFieldValue(v, values[0], opts); err != nil {
+			return &BindError{Field: key, Value: values[0], Err: err}
+		}
+		return nil
+	case indirectType(t).Kind() == reflect.Struct:
+		prefix := key + "."
+		if !d.hasPrefix(prefix) {
+			return nil
+		}
+
+		if t.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(t.Elem()))
+			}
+			v = v.Elem()
+		}
+
+		return d.decodeStruct(v, prefix)
+	default:
+		return nil
+	}
+}
+
+// hasPrefix reports whether there is a value or a file with a key which starts with the "prefix".
+func (d *valuesDecoder) hasPrefix(prefix string) bool {
+	for key := range d.values {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	for key := range d.files {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseFieldTag returns the name and the options of a field's struct tag, i.e "date,layout=2006-01-02".
+func parseFieldTag(tag string) (name string, opts string) {
+	if i := strings.IndexByte(tag, ','); i != -1 {
+		return tag[:i], tag[i+1:]
+	}
+
+	return tag, ""
+}
+
+// fieldTagOption returns the value of the "key=value" option of a field's struct tag options.
+func fieldTagOption(opts, key string) string {
+	for _, opt := range strings.Split(opts, ",") {
+		if k, v, ok := strings.Cut(opt, "="); ok && k == key {
+			return v
+		}
+	}
+
+	return ""
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+
+	return t
+}
+
+// isLeafType reports whether a value of type "t" can be parsed from a single string by the `setFieldValue`.
+func isLeafType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// setFieldValue parses the "value" and sets it to the "v" field, see `isLeafType`.
+func setFieldValue(v reflect.Value, value string, opts string) error {
+	if v.Kind() == reflect.Ptr {
+		ptr := reflect.New(v.Type().Elem())
+		if err := setFieldValue(ptr.Elem(), value, opts); err != nil {
+			return err
+		}
+
+		v.Set(ptr)
+		return nil
+	}
+
+	t := v.Type()
+	switch {
+	case t == timeType:
+		layout := fieldTagOption(opts, "layout")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+
+		tm, err := time.Parse(layout, value)
+		if err != nil {
+			return err
+		}
+
+		v.Set(reflect.ValueOf(tm))
+		return nil
+	case t == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(d))
+		return nil
+	case reflect.PtrTo(t).Implements(textUnmarshalerType):
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return numError(err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, t.Bits())
+		if err != nil {
+			return numError(err)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, t.Bits())
+		if err != nil {
+			return numError(err)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, t.Bits())
+		if err != nil {
+			return numError(err)
+		}
+		v.SetFloat(n)
+	}
+
+	return nil
+}
+
+// numError returns the reason of a *strconv.NumError, i.e the strconv.ErrSyntax.
+func numError(err error) error {
+	if numErr, ok := err.(*strconv.NumError); ok {
+		return numErr.Err
+	}
+
+	return err
+}
@@ -0,0 +1,219 @@
+package muxie
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryBinder(t *testing.T) {
+	type address struct {
+		City string `query:"city"`
+		Zip  *int   `query:"zip"`
+	}
+
+	type Paging struct {
+		Page int `query:"page"`
+	}
+
+	type filter struct {
+		Paging
+		Name     string        `query:"name"`
+		Tags     []string      `query:"tag"`
+		IDs      []uint        `query:"id"`
+		Active   *bool         `query:"active"`
+		Since    time.Time     `query:"since,layout=2006-01-02"`
+		Until    time.Time     `query:"until"`
+		TTL      time.Duration `query:"ttl"`
+		Home     address       `query:"home"`
+		Work     *address      `query:"work"`
+		Other    *address      `query:"other"`
+		Ignored  string        `query:"-"`
+		NoTag    string
+		internal string
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/?"+url.Values{
+		"page":      {"2"},
+		"name":      {"kataras"},
+		"tag":       {"a", "b"},
+		"id":        {"1", "2", "3"},
+		"active":    {"true"},
+		"since":     {"2020-01-02"},
+		"until":     {"2020-01-03T10:00:00Z"},
+		"ttl":       {"1h30m"},
+		"home.city": {"Athens"},
+		"work.city": {"Thessaloniki"},
+		"work.zip":  {"54621"},
+		"Ignored":   {"x"},
+		"NoTag":     {"y"},
+		"internal":  {"z"},
+	}.Encode(), nil)
+
+	var f filter
+	if err := Bind(r, Query, &f); err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Page != 2 || f.Name != "kataras" || f.NoTag != "y" || f.Ignored != "" || f.internal != "" {
+		t.Fatalf("unexpected scalar fields: %#v", f)
+	}
+	if strings.Join(f.Tags, ",") != "a,b" || len(f.IDs) != 3 || f.IDs[2] != 3 {
+		t.Fatalf("unexpected slice fields: %v %v", f.Tags, f.IDs)
+	}
+	if f.Active == nil || !*f.Active {
+		t.Fatalf("expected active to be set")
+	}
+	if f.Since.Format("2006-01-02") != "2020-01-02" || f.Until.Hour() != 10 || f.TTL != 90*time.Minute {
+		t.Fatalf("unexpected time fields: %v %v %v", f.Since, f.Until, f.TTL)
+	}
+	if f.Home.City != "Athens" || f.Home.Zip != nil {
+		t.Fatalf("unexpected home: %#v", f.Home)
+	}
+	if f.Work == nil || f.Work.City != "Thessaloniki" || f.Work.Zip == nil || *f.Work.Zip != 54621 {
+		t.Fatalf("unexpected work: %#v", f.Work)
+	}
+	if f.Other != nil {
+		t.Fatalf("expected a nil pointer for missing nested values")
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/?home.zip=abc", nil)
+	err := Bind(r, Query, &f)
+
+	var bindErr *BindError
+	if !errors.As(err, &bindErr) || bindErr.Field != "home.zip" || bindErr.Value != "abc" || !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatalf("expected a bind error of home.zip but got: %v", err)
+	}
+	if p := ProblemOf(err); p.Status != http.StatusBadRequest {
+		t.Fatalf("expected status code: %d but got: %d", http.StatusBadRequest, p.Status)
+	}
+
+	if err = Bind(r, Query, f); err == nil {
+		t.Fatalf("expected an error for a non-pointer value")
+	}
+}
+
+func TestFormBinder(t *testing.T) {
+	type login struct {
+		Username string `form:"username"`
+		Remember bool   `form:"remember"`
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/?username=query", strings.NewReader("username=kataras&remember=1"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var l login
+	if err := Bind(r, Form, &l); err != nil {
+		t.Fatal(err)
+	}
+
+	if l.Username != "kataras" || !l.Remember {
+		t.Fatalf("unexpected form: %#v", l)
+	}
+}
+
+func TestMultipartBinder(t *testing.T) {
+	type upload struct {
+		Title  string                  `form:"title"`
+		Avatar *multipart.FileHeader   `form:"avatar"`
+		Files  []*multipart.FileHeader `form:"files"`
+	}
+
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	mw.WriteField("title", "photos")
+	for _, name := range []string{"avatar", "files", "files"} {
+		fw, _ := mw.CreateFormFile(name, name+".png")
+		fw.Write([]byte("png"))
+	}
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var u upload
+	if err := Bind(r, Multipart, &u); err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Title != "photos" || u.Avatar == nil || u.Avatar.Filename != "avatar.png" || len(u.Files) != 2 {
+		t.Fatalf("unexpected upload: %#v", u)
+	}
+}
+
+func TestCombineBinders(t *testing.T) {
+	type updateUser struct {
+		ID     int    `path:"id"`
+		Notify bool   `query:"notify"`
+		Name   string `json:"name"`
+	}
+
+	mux := NewMux()
+	mux.ContextParams = true
+	mux.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		var req updateUser
+		if err := Bind(r, Combine(Path, Query, JSON), &req); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		Dispatch(w, JSON, req)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	expectWithBody(t, http.MethodPut, srv.URL+"/users/42?notify=true", `{"name":"kataras"}`, nil).
+		statusCode(http.StatusOK).bodyEq(`{"ID":42,"Notify":true,"name":"kataras"}`)
+
+	expectWithBody(t, http.MethodPut, srv.URL+"/users/abc", `{}`, nil).
+		statusCode(http.StatusBadRequest)
+
+	// the JSON is skipped when the request has no body.
+	expect(t, http.MethodGet, srv.URL+"/users/42").
+		statusCode(http.StatusOK).bodyEq(`{"ID":42,"Notify":false,"name":""}`)
+}
+
+func TestBinderErrors(t *testing.T) {
+	type login struct {
+		Username string `form:"username" query:"username"`
+	}
+
+	newRequest := func(contentType, body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		return r
+	}
+
+	tests := []struct {
+		r        *http.Request
+		b        Binder
+		v        interface{}
+		status   int
+		expected interface{}
+	}{
+		{newRequest("application/x-www-form-urlencoded", "username=%zz"), Form, &login{}, http.StatusBadRequest, new(*MalformedBodyError)},
+		{newRequest("application/x-www-form-urlencoded", "username=kataras"), Multipart, &login{}, http.StatusUnsupportedMediaType, new(*NegotiationError)},
+		{newRequest("multipart/form-data", "username=kataras"), Multipart, &login{}, http.StatusBadRequest, new(*MalformedBodyError)},
+		{newRequest("application/x-www-form-urlencoded", "username=kataras"), Form, login{}, http.StatusInternalServerError, new(*InvalidBindTargetError)},
+		{newRequest("", ""), Query, new(string), http.StatusInternalServerError, new(*InvalidBindTargetError)},
+	}
+
+	for i, tt := range tests {
+		err := Bind(tt.r, tt.b, tt.v)
+		if !errors.As(err, tt.expected) {
+			t.Fatalf("[%d] expected error of type: %T but got: %T (%v)", i, tt.expected, err, err)
+		}
+
+		if got := ProblemOf(err).Status; got != tt.status {
+			t.Fatalf("[%d] expected status code: %d but got: %d", i, tt.status, got)
+		}
+	}
+}
@@ -4,14 +4,24 @@ import (
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
-	"io/ioutil"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"reflect"
+	"strconv"
 )
 
 var (
 	// Charset is the default content type charset for Request Processors .
 	Charset = "utf-8"
 
+	// MaxBodySize is the default maximum size, in bytes, of the request body
+	// that the `JSON` and `XML` processors read, a larger body fails with a `BodyTooLargeError`.
+	// Each processor can override it through its own MaxBodySize field.
+	// Zero or negative means no limit. Defaults to 10MB.
+	MaxBodySize int64 = 10 << 20
+
 	// JSON implements the full `Processor` interface.
 	// It is responsible to dispatch JSON results to the client and to read JSON
 	// data from the request body.
@@ -21,6 +31,11 @@ var (
 	// muxie.Bind(r, muxie.JSON, &myStructValue)
 	// To send a response:
 	// muxie.Dispatch(w, muxie.JSON, mySendDataValue)
+	//
+	// A copy can be used to bind with different options, i.e:
+	// strict := *muxie.JSON
+	// strict.DisallowUnknownFields = true
+	// muxie.Bind(r, &strict, &myStructValue)
 	JSON = &jsonProcessor{Prefix: nil, Indent: "", UnescapeHTML: false}
 
 	// XML implements the full `Processor` interface.
@@ -65,6 +80,123 @@ func Dispatch(w http.ResponseWriter, d Dispatcher, v interface{}) error {
 	return d.Dispatch(w, v)
 }
 
+// DispatchStatus is like `Dispatch` but it sends the "v" with the "status" code, i.e:
+//
+//	w.Header().Set("Location", "/users/42")
+//	muxie.DispatchStatus(w, muxie.JSON, http.StatusCreated, user)
+//
+// The status code is written right before the body, so the headers
+// that the `Dispatcher` sets, i.e the "Content-Type", are kept.
+// A nil "v" sends only the status code, i.e http.StatusNoContent.
+// On error nothing is written, so the caller can still send an error response, see `WriteError`.
+func DispatchStatus(w http.ResponseWriter, d Dispatcher, status int, v interface{}) error {
+	if v == nil {
+		w.WriteHeader(status)
+		return nil
+	}
+
+	sw := &statusResponseWriter{ResponseWriter: w, status: status}
+	if err := d.Dispatch(sw, v); err != nil {
+		return err
+	}
+
+	// the dispatcher did not write a body.
+	sw.writeHeader()
+	return nil
+}
+
+// statusResponseWriter delays the status code until the first Write,
+// after the headers of a `Dispatcher` are set.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// WriteHeader sends the "code" instead of the status code of the `DispatchStatus`,
+// for dispatchers which set their own.
+func (w *statusResponseWriter) WriteHeader(code int) {
+	w.status = code
+	w.writeHeader()
+}
+
+func (w *statusResponseWriter) writeHeader() {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	w.writeHeader()
+	return w.ResponseWriter.Write(b)
+}
+
+// Response is a response envelope of a status code, headers, cookies and a body,
+// it is sent to the client through `DispatchResponse`, i.e:
+//
+//	res := muxie.NewResponse(http.StatusCreated, user).SetHeader("Location", "/users/42")
+//	muxie.DispatchResponse(w, muxie.JSON, res)
+type Response struct {
+	// Status is the status code, zero means http.StatusOK.
+	Status int
+	// Header are the headers which are added to the response.
+	Header http.Header
+	// Cookies are the cookies which are set to the response.
+	Cookies []*http.Cookie
+	// Body is the value that the `Dispatcher` sends, nil sends only the status code and the headers.
+	Body interface{}
+}
+
+// NewResponse returns a new `Response` of the "status" code and the "body".
+func NewResponse(status int, body interface{}) *Response {
+	return &Response{Status: status, Body: body}
+}
+
+// SetHeader sets the "key" header to the "value".
+// It returns the Response itself, so calls can be chained.
+func (res *Response) SetHeader(key, value string) *Response {
+	if res.Header == nil {
+		res.Header = make(http.Header)
+	}
+
+	res.Header.Set(key, value)
+	return res
+}
+
+// SetCookie adds the "cookie" to the response.
+// It returns the Response itself, so calls can be chained.
+func (res *Response) SetCookie(cookie *http.Cookie) *Response {
+	res.Cookies = append(res.Cookies, cookie)
+	return res
+}
+
+// DispatchResponse sends the "res" `Response` to the client,
+// its headers and cookies first and then its body through the "d" `Dispatcher`, see `DispatchStatus`.
+func DispatchResponse(w http.ResponseWriter, d Dispatcher, res *Response) error {
+	header := w.Header()
+	for key, values := range res.Header {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+
+	for _, cookie := range res.Cookies {
+		http.SetCookie(w, cookie)
+	}
+
+	status := res.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return DispatchStatus(w, d, status, res.Body)
+}
+
 // Processor implements both `Binder` and `Dispatcher` interfaces.
 // It is used for implementations that can `Bind` and `Dispatch`
 // the same data form.
@@ -92,17 +224,50 @@ type jsonProcessor struct {
 	Prefix       []byte
 	Indent       string
 	UnescapeHTML bool
+
+	// MaxBodySize overrides the global `MaxBodySize` when not zero, negative means no limit.
+	MaxBodySize int64
+	// DisallowUnknownFields fails the Bind when the body has keys which do not match a struct field.
+	DisallowUnknownFields bool
+	// UseNumber decodes the numbers of an interface{} value as json.Number instead of float64.
+	UseNumber bool
 }
 
 var _ Processor = (*jsonProcessor)(nil)
 
+func (p *jsonProcessor) bindsBody() bool { return true }
+
 func (p *jsonProcessor) Bind(r *http.Request, v interface{}) error {
-	b, err := ioutil.ReadAll(r.Body)
+	if err := checkBindTarget(v, false); err != nil {
+		return err
+	}
+
+	body, err := limitBody(r, p.MaxBodySize)
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(b, v)
+	dec := json.NewDecoder(body)
+	if p.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if p.UseNumber {
+		dec.UseNumber()
+	}
+
+	if err = dec.Decode(v); err != nil {
+		return bodyError(err)
+	}
+
+	// only whitespace is allowed after the value.
+	if _, err = dec.Token(); err != io.EOF {
+		if err == nil {
+			err = ErrTrailingData
+		}
+		return bodyError(err)
+	}
+
+	return nil
 }
 
 func (p *jsonProcessor) Dispatch(w http.ResponseWriter, v interface{}) error {
@@ -142,17 +307,51 @@ func (p *jsonProcessor) Dispatch(w http.ResponseWriter, v interface{}) error {
 
 type xmlProcessor struct {
 	Indent string
+
+	// MaxBodySize overrides the global `MaxBodySize` when not zero, negative means no limit.
+	MaxBodySize int64
 }
 
 var _ Processor = (*xmlProcessor)(nil)
 
+func (p *xmlProcessor) bindsBody() bool { return true }
+
 func (p *xmlProcessor) Bind(r *http.Request, v interface{}) error {
-	b, err := ioutil.ReadAll(r.Body)
+	if err := checkBindTarget(v, false); err != nil {
+		return err
+	}
+
+	body, err := limitBody(r, p.MaxBodySize)
 	if err != nil {
 		return err
 	}
 
-	return xml.Unmarshal(b, v)
+	dec := xml.NewDecoder(body)
+	if err = dec.Decode(v); err != nil {
+		return bodyError(err)
+	}
+
+	// only whitespace, comments and processing instructions are allowed after the root element.
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return bodyError(err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.Comment, xml.ProcInst:
+			continue
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) == 0 {
+				continue
+			}
+		}
+
+		return bodyError(ErrTrailingData)
+	}
 }
 
 func (p *xmlProcessor) Dispatch(w http.ResponseWriter, v interface{}) error {
@@ -179,3 +378,135 @@ func (p *xmlProcessor) Dispatch(w http.ResponseWriter, v interface{}) error {
 	_, err = w.Write(result)
 	return err
 }
+
+// ErrTrailingData is the `MalformedBodyError.Err` when the request body has more data after its value.
+var ErrTrailingData = errors.New("unexpected data after the top-level value")
+
+// BodyTooLargeError is returned by the `Bind` of the `JSON` and `XML` processors
+// when the request body exceeds their `MaxBodySize`.
+// It can be sent to the client through `WriteError`, as 413 `Problem`.
+type BodyTooLargeError struct {
+	// Limit is the maximum size of the body, in bytes.
+	Limit int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("muxie: request body too large, limit: %d bytes", e.Limit)
+}
+
+// Problem returns the 413 `Problem` of the error, see `WriteError`.
+func (e *BodyTooLargeError) Problem() *Problem {
+	return NewProblem(http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds the limit of %d bytes", e.Limit))
+}
+
+// MalformedBodyError is returned by the `Bind` of the `JSON` and `XML` processors
+// when the request body is empty, it is not valid or it has more data after its value,
+// and by the `Form` and `Multipart` binders when the request body cannot be parsed.
+// The values of a wrong type are reported as `BindError`.
+// It can be sent to the client through `WriteError`, as 400 `Problem`.
+type MalformedBodyError struct {
+	// Err is the decode error, i.e the *json.SyntaxError, the io.EOF of an empty body or the `ErrTrailingData`.
+	Err error
+}
+
+func (e *MalformedBodyError) Error() string {
+	return "muxie: malformed request body: " + e.Err.Error()
+}
+
+// Unwrap returns the reason of the error, so it can be checked with `errors.Is`.
+func (e *MalformedBodyError) Unwrap() error {
+	return e.Err
+}
+
+// Problem returns the 400 `Problem` of the error, see `WriteError`.
+func (e *MalformedBodyError) Problem() *Problem {
+	detail := e.Err.Error()
+	if e.Err == io.EOF {
+		detail = "empty body"
+	}
+
+	return NewProblem(http.StatusBadRequest, "malformed request body: "+detail)
+}
+
+// InvalidBindTargetError is returned by the `Bind` of the `JSON` and `XML` processors
+// when the value to bind to is not a non-nil pointer and by the `Form`, `Multipart`, `Query` and `Path` binders
+// when it is not a non-nil pointer to a struct.
+// It is a programming error, not a client one, therefore `WriteError` sends it as 500 `Problem`.
+type InvalidBindTargetError struct {
+	// Type is the type of the value, nil if the value is nil.
+	Type reflect.Type
+	// Struct reports whether a pointer to a struct was expected.
+	Struct bool
+}
+
+func (e *InvalidBindTargetError) Error() string {
+	expected := "a non-nil pointer"
+	if e.Struct {
+		expected += " to a struct"
+	}
+
+	got := "nil"
+	if e.Type != nil {
+		got = e.Type.String()
+	}
+
+	return "muxie: bind: expected " + expected + " but got " + got
+}
+
+// checkBindTarget returns an `InvalidBindTargetError` if the "v" is not a non-nil pointer
+// or, if "toStruct" is true, a non-nil pointer to a struct.
+func checkBindTarget(v interface{}, toStruct bool) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || (toStruct && rv.Elem().Kind() != reflect.Struct) {
+		return &InvalidBindTargetError{Type: reflect.TypeOf(v), Struct: toStruct}
+	}
+
+	return nil
+}
+
+// isEmptyBody reports whether the request has no body.
+func isEmptyBody(r *http.Request) bool {
+	return r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0
+}
+
+// limitBody returns the request body limited to the "limit" bytes or,
+// if zero, to the global `MaxBodySize`.
+func limitBody(r *http.Request, limit int64) (io.Reader, error) {
+	if limit == 0 {
+		limit = MaxBodySize
+	}
+
+	if r.Body == nil {
+		return http.NoBody, nil
+	}
+
+	if limit <= 0 {
+		return r.Body, nil
+	}
+
+	if r.ContentLength > limit {
+		return nil, &BodyTooLargeError{Limit: limit}
+	}
+
+	return http.MaxBytesReader(nil, r.Body, limit), nil
+}
+
+// bodyError converts a decode error to a `BodyTooLargeError`, a `BindError` or a `MalformedBodyError`.
+func bodyError(err error) error {
+	var (
+		maxBytesErr *http.MaxBytesError
+		typeErr     *json.UnmarshalTypeError
+		numErr      *strconv.NumError
+	)
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &BodyTooLargeError{Limit: maxBytesErr.Limit}
+	case errors.As(err, &typeErr):
+		return &BindError{Field: typeErr.Field, Value: typeErr.Value, Err: errors.New("expected " + typeErr.Type.String())}
+	case errors.As(err, &numErr): // the xml decoder does not report the field.
+		return &BindError{Value: numErr.Num, Err: numErr.Err}
+	default:
+		return &MalformedBodyError{Err: err}
+	}
+}
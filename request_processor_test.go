@@ -1,10 +1,14 @@
 package muxie
 
 import (
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -61,3 +65,139 @@ func TestJSON(t *testing.T) {
 func TestXML(t *testing.T) {
 	testProcessor(t, XML, "text/xml", `<person name="%s" age="%d"><description>%s</description></person>`)
 }
+
+func TestProcessorBindErrors(t *testing.T) {
+	strict := *JSON
+	strict.DisallowUnknownFields = true
+
+	small := *JSON
+	small.MaxBodySize = 16
+
+	smallXML := *XML
+	smallXML.MaxBodySize = 16
+
+	unlimited := *JSON
+	unlimited.MaxBodySize = -1
+
+	tests := []struct {
+		p        Processor
+		body     string
+		status   int
+		expected interface{}
+	}{
+		{JSON, `{"name":"kataras"}`, 0, nil},
+		{JSON, `{"name":"kataras"}  ` + "\n", 0, nil},
+		{JSON, ``, http.StatusBadRequest, new(*MalformedBodyError)},
+		{JSON, `{"name":`, http.StatusBadRequest, new(*MalformedBodyError)},
+		{JSON, `{"name":"kataras"} {}`, http.StatusBadRequest, new(*MalformedBodyError)},
+		{JSON, `{"name":"kataras"}}`, http.StatusBadRequest, new(*MalformedBodyError)},
+		{JSON, `{"age":"25"}`, http.StatusBadRequest, new(*BindError)},
+		{JSON, `{"other":1}`, 0, nil},
+		{&strict, `{"other":1}`, http.StatusBadRequest, new(*MalformedBodyError)},
+		{&small, `{"name":"kataras"}`, http.StatusRequestEntityTooLarge, new(*BodyTooLargeError)},
+		{&unlimited, `{"name":"` + strings.Repeat("a", 1<<10) + `"}`, 0, nil},
+		{XML, `<person name="kataras"></person><!-- end -->`, 0, nil},
+		{XML, `<person name="kataras"></person><person></person>`, http.StatusBadRequest, new(*MalformedBodyError)},
+		{XML, `<person age="x"></person>`, http.StatusBadRequest, new(*BindError)},
+		{&smallXML, `<person name="kataras"></person>`, http.StatusRequestEntityTooLarge, new(*BodyTooLargeError)},
+	}
+
+	for i, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+		// hide the content length, so the limit is checked while reading too.
+		r.Body = io.NopCloser(r.Body)
+		r.ContentLength = -1
+
+		var v person
+		err := Bind(r, tt.p, &v)
+		if tt.expected == nil {
+			if err != nil {
+				t.Fatalf("[%d] %s: unexpected error: %v", i, tt.body, err)
+			}
+			continue
+		}
+
+		if !errors.As(err, tt.expected) {
+			t.Fatalf("[%d] %s: expected error of type: %T but got: %T (%v)", i, tt.body, tt.expected, err, err)
+		}
+
+		if got := ProblemOf(err).Status; got != tt.status {
+			t.Fatalf("[%d] %s: expected status code: %d but got: %d", i, tt.body, tt.status, got)
+		}
+	}
+
+	// the content length is checked before reading.
+	small.MaxBodySize = 1
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	if err := Bind(r, &small, &person{}); !errors.As(err, new(*BodyTooLargeError)) {
+		t.Fatalf("expected a body too large error but got: %v", err)
+	}
+
+	// the value to bind to must be a non-nil pointer, for any request body.
+	for _, p := range []Processor{JSON, XML} {
+		for _, v := range []interface{}{nil, person{}, (*person)(nil)} {
+			r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{`))
+			err := Bind(r, p, v)
+			if !errors.As(err, new(*InvalidBindTargetError)) {
+				t.Fatalf("%T: expected an invalid bind target error for: %#v but got: %v", p, v, err)
+			}
+
+			if got := ProblemOf(err).Status; got != http.StatusInternalServerError {
+				t.Fatalf("%T: expected status code: %d but got: %d", p, http.StatusInternalServerError, got)
+			}
+		}
+	}
+
+	// the UseNumber decodes the numbers of interface{} values as json.Number.
+	number := *JSON
+	number.UseNumber = true
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"n":1.50}`))
+	var m map[string]interface{}
+	if err := Bind(r, &number, &m); err != nil || m["n"] != json.Number("1.50") {
+		t.Fatalf("expected a json number but got: %#v (%v)", m["n"], err)
+	}
+}
+
+func TestDispatchStatus(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc("/created", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/users/42")
+		DispatchStatus(w, JSON, http.StatusCreated, map[string]int{"id": 42})
+	})
+	mux.HandleFunc("/accepted", func(w http.ResponseWriter, r *http.Request) {
+		res := NewResponse(http.StatusAccepted, map[string]string{"status": "queued"}).
+			SetHeader("Retry-After", "10").
+			SetCookie(&http.Cookie{Name: "job", Value: "7"})
+
+		DispatchResponse(w, JSON, res)
+	})
+	mux.HandleFunc("/no-content", func(w http.ResponseWriter, r *http.Request) {
+		DispatchResponse(w, JSON, NewResponse(http.StatusNoContent, nil))
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		if err := DispatchStatus(w, JSON, http.StatusCreated, func() {}); err != nil {
+			WriteError(w, err)
+		}
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	expect(t, http.MethodPost, srv.URL+"/created").statusCode(http.StatusCreated).
+		headerEq("Content-Type", withCharset("application/json")).
+		headerEq("Location", "/users/42").
+		bodyEq(`{"id":42}`)
+
+	expect(t, http.MethodPost, srv.URL+"/accepted").statusCode(http.StatusAccepted).
+		headerEq("Content-Type", withCharset("application/json")).
+		headerEq("Retry-After", "10").
+		headerEq("Set-Cookie", "job=7").
+		bodyEq(`{"status":"queued"}`)
+
+	expect(t, http.MethodDelete, srv.URL+"/no-content").statusCode(http.StatusNoContent).
+		headerEq("Content-Type", "").
+		bodyEq("")
+
+	expect(t, http.MethodPost, srv.URL+"/error").statusCode(http.StatusInternalServerError).
+		headerEq("Content-Type", ProblemContentType)
+}
@@ -0,0 +1,90 @@
+package muxie
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+)
+
+// Route describes a registered route, see `Mux#RouteTable`.
+type Route struct {
+	// Pattern is the full path pattern of the route, i.e "/v1/users/:id|int".
+	Pattern string
+	// Tag is the route's tag, see `WithTag`.
+	Tag string
+	// Data is the route's optional data, see `WithData`.
+	Data interface{}
+	// ParamKeys are the named parameters and wildcard keys of the Pattern, without : or *.
+	ParamKeys []string
+	// Methods are the HTTP methods that the route serves when its handler is a `MethodHandler`,
+	// empty means that the handler is responsible for all methods.
+	Methods []string
+	// Handler is the route's handler, without the `Mux#Use` and `With` middlewares.
+	Handler http.Handler
+}
+
+// RouteTable is the list of the registered routes.
+// Its `String` method dumps the routes as a table.
+type RouteTable []Route
+
+// String returns the routes as a table of methods, patterns, tags and parameter keys, i.e:
+//
+//	METHODS      PATTERN          TAG   PARAMS
+//	GET, DELETE  /users/:id|int   user  id
+//	*            /about
+func (rt RouteTable) String() string {
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	w.Write([]byte("METHODS\tPATTERN\tTAG\tPARAMS\n"))
+
+	for _, r := range rt {
+		methods := WildcardParamStart
+		if len(r.Methods) > 0 {
+			methods = strings.Join(r.Methods, ", ")
+		}
+
+		w.Write([]byte(methods + "\t" + r.Pattern + "\t" + r.Tag + "\t" + strings.Join(r.ParamKeys, ", ") + "\n"))
+	}
+
+	w.Flush()
+
+	// remove the padding of the empty last columns.
+	lines := strings.Split(b.String(), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// RouteTable returns the registered routes of this Mux and its SubMuxes,
+// sorted by their static path segments, see `Trie#Walk` too.
+func (m *Mux) RouteTable() RouteTable {
+	var rt RouteTable
+
+	m.CurrentRoutes().Walk(func(n *Node) error {
+		handler := n.main
+		if handler == nil {
+			handler = n.Handler
+		}
+
+		var methods []string
+		if mh, ok := handler.(*MethodHandler); ok {
+			methods = append(methods, mh.methods...)
+		}
+
+		rt = append(rt, Route{
+			Pattern:   n.Key(),
+			Tag:       n.Tag,
+			Data:      n.Data,
+			ParamKeys: n.ParamKeys(),
+			Methods:   methods,
+			Handler:   handler,
+		})
+
+		return nil
+	})
+
+	return rt
+}
@@ -0,0 +1,67 @@
+package muxie
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestTrieWalk(t *testing.T) {
+	tree := NewTrie()
+	for _, pattern := range []string{"/users/*path", "/users/:name", "/users/:id|int", "/users/new", "/about", "/"} {
+		tree.Insert(pattern)
+	}
+
+	var keys []string
+	tree.Walk(func(n *Node) error {
+		keys = append(keys, n.Key())
+		return nil
+	})
+
+	if expected := []string{"/", "/about", "/users/new", "/users/:id|int", "/users/:name", "/users/*path"}; !reflect.DeepEqual(expected, keys) {
+		t.Fatalf("expected keys: %v but got: %v", expected, keys)
+	}
+
+	errStop := errors.New("stop")
+	visited := 0
+	if err := tree.Walk(func(n *Node) error {
+		visited++
+		return errStop
+	}); err != errStop || visited != 1 {
+		t.Fatalf("expected walk to stop on first error")
+	}
+}
+
+func TestMuxRouteTable(t *testing.T) {
+	noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	mux := NewMux()
+	mux.Use(func(next http.Handler) http.Handler { return next })
+	mux.Handle("/about", noop)
+
+	v1 := mux.Of("/v1")
+	userHandler := Methods().Handle("GET, DELETE", noop)
+	v1.Handle("/users/:id|int", userHandler, WithTag("user"), WithData(42))
+
+	rt := mux.RouteTable()
+	if expected, got := 2, len(rt); expected != got {
+		t.Fatalf("expected %d routes but got: %d", expected, got)
+	}
+
+	user := rt[1]
+	if user.Pattern != "/v1/users/:id|int" || user.Tag != "user" || user.Data != 42 ||
+		!reflect.DeepEqual(user.ParamKeys, []string{"id"}) ||
+		!reflect.DeepEqual(user.Methods, []string{"GET", "DELETE"}) ||
+		user.Handler != userHandler {
+		t.Fatalf("unexpected route: %#+v", user)
+	}
+
+	expectedTable := `METHODS      PATTERN            TAG   PARAMS
+*            /about
+GET, DELETE  /v1/users/:id|int  user  id
+`
+	if got := rt.String(); expectedTable != got {
+		t.Fatalf("expected table:\n%s\nbut got:\n%s", expectedTable, got)
+	}
+}
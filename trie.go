@@ -1,12 +1,17 @@
 package muxie
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 )
 
+// Path patterns can also be written in the net/http ServeMux (Go 1.22+) form,
+// i.e "/users/{id}", "/files/{path...}" and "/docs/{$}".
 const (
 	// ParamStart is the character, as a string, which a path pattern starts to define its named parameter.
+	// A named parameter may be followed by a constraint, i.e ":id|int", see `ParamConstraintStart`.
 	ParamStart = ":"
 	// WildcardParamStart is the character, as a string, which a path pattern starts to define its named parameter for wildcards.
 	// It allows everything else after that path prefix
@@ -59,7 +64,8 @@ func WithHandler(handler http.Handler) InsertOption {
 	}
 }
 
-// WithTag sets the node's `Tag` field (may be useful for HTTP).
+// WithTag sets the node's `Tag` field (may be useful for HTTP),
+// i.e a route name which its path can be built from, see `BuildPath`.
 func WithTag(tag string) InsertOption {
 	return func(n *Node) {
 		if n.Tag == "" {
@@ -68,6 +74,17 @@ func WithTag(tag string) InsertOption {
 	}
 }
 
+// With wraps the node's `Handler` with the "middlewares", i.e
+// `mux.Handle("/admin", adminHandler, muxie.With(auth, rateLimit))`.
+// Order matters, the first middleware runs first, like the `Pre` function.
+// When used with the `Mux` they run after the `Mux#Use` ones and only for that route (and method),
+// see `Mux#With` too.
+func With(middlewares ...Wrapper) InsertOption {
+	return func(n *Node) {
+		n.wrappers = append(n.wrappers, middlewares...)
+	}
+}
+
 // WithData sets the node's optionally `Data` field.
 func WithData(data interface{}) InsertOption {
 	return func(n *Node) {
@@ -86,6 +103,190 @@ func (t *Trie) Insert(pattern string, options ...InsertOption) {
 	for _, opt := range options {
 		opt(n)
 	}
+	n.wrapHandler()
+}
+
+var (
+	// ErrRouteExists is the `RouteError.Err` when the same path pattern is already registered.
+	ErrRouteExists = errors.New("route already registered")
+	// ErrParamNameConflict is the `RouteError.Err` when a path pattern resolves to an already registered one
+	// but with different named parameter or wildcard names, i.e "/a/:x" and "/a/:y".
+	ErrParamNameConflict = errors.New("conflicting parameter names")
+	// ErrWildcardNotLast is the `RouteError.Err` when a wildcard is followed by more path segments, i.e "/a/*w/b".
+	ErrWildcardNotLast = errors.New("wildcard must be the last path segment")
+	// ErrEmptySegment is the `RouteError.Err` when a path pattern contains an empty path segment, i.e "/a//b".
+	ErrEmptySegment = errors.New("empty path segment")
+	// ErrTagExists is the `RouteError.Err` when the tag of a path pattern, see `WithTag`,
+	// is already registered to another path pattern or the path pattern is already registered with another tag,
+	// i.e a different method of the same `Mux` route.
+	ErrTagExists = errors.New("tag already registered")
+)
+
+// RouteError describes why a path pattern cannot be registered.
+// It is returned by the `Trie#TryInsert` and `Mux#Register` methods.
+type RouteError struct {
+	// Pattern is the path pattern that failed to be registered.
+	Pattern string
+	// Existing is the already registered path pattern which the Pattern conflicts with, if any.
+	Existing string
+	// Err is the reason, i.e `ErrRouteExists` or a parameter constraint error.
+	Err error
+}
+
+func (e *RouteError) Error() string {
+	if e.Existing != "" {
+		return fmt.Sprintf("muxie: %s: %v with the already registered %s", e.Pattern, e.Err, e.Existing)
+	}
+
+	return fmt.Sprintf("muxie: %s: %v", e.Pattern, e.Err)
+}
+
+// Unwrap returns the reason of the error, so it can be checked with `errors.Is`.
+func (e *RouteError) Unwrap() error {
+	return e.Err
+}
+
+// TryInsert is like `Insert` but instead of overriding an already registered node
+// it returns a `*RouteError` describing the conflict, for duplicate routes,
+// conflicting parameter names, wildcards followed by more path segments and duplicate tags,
+// or an invalid parameter constraint. The trie is left untouched on errors.
+func (t *Trie) TryInsert(pattern string, options ...InsertOption) error {
+	input, existing, err := t.checkPattern(pattern)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		return newRouteConflictError(pattern, existing, input)
+	}
+
+	if err = t.checkTag(pattern, nil, options); err != nil {
+		return err
+	}
+
+	t.Insert(pattern, options...)
+	return nil
+}
+
+// checkTag returns a `*RouteError` of `ErrTagExists` when the tag that the "options" set, if any,
+// is already registered to a node other than the "self" one or the "self" has a different tag.
+func (t *Trie) checkTag(pattern string, self *Node, options []InsertOption) error {
+	probe := new(Node)
+	for _, opt := range options {
+		opt(probe)
+	}
+
+	if probe.Tag == "" {
+		return nil
+	}
+
+	if self != nil && self.Tag != "" && self.Tag != probe.Tag {
+		return &RouteError{Pattern: pattern, Existing: self.key, Err: ErrTagExists}
+	}
+
+	if found := t.root.findTag(probe.Tag); found != nil && found != self {
+		return &RouteError{Pattern: pattern, Existing: found.key, Err: ErrTagExists}
+	}
+
+	return nil
+}
+
+// checkPattern validates the path pattern's segments and returns them
+// with the already registered node that the pattern resolves to, if any.
+func (t *Trie) checkPattern(pattern string) ([]string, *Node, error) {
+	if pattern == "" {
+		return nil, nil, &RouteError{Pattern: pattern, Err: errors.New("empty pattern")}
+	}
+
+	converted, err := convertPattern(pattern)
+	if err != nil {
+		return nil, nil, &RouteError{Pattern: pattern, Err: err}
+	}
+
+	input := slowPathSplit(converted)
+	for i, s := range input {
+		if s == "" {
+			if i == len(input)-1 { // trailing slash.
+				continue
+			}
+
+			return nil, nil, &RouteError{Pattern: pattern, Err: ErrEmptySegment}
+		}
+
+		switch s[0] {
+		case ParamStart[0]:
+			if _, constraintExpr := splitParamConstraint(s[1:]); constraintExpr != "" {
+				if _, err := parseParamConstraint(constraintExpr); err != nil {
+					return nil, nil, &RouteError{Pattern: pattern, Err: err}
+				}
+			}
+		case WildcardParamStart[0]:
+			if i < len(input)-1 {
+				return nil, nil, &RouteError{Pattern: pattern, Err: ErrWildcardNotLast}
+			}
+		}
+	}
+
+	if existing := t.searchPattern(input); existing != nil && existing.end {
+		return input, existing, nil
+	}
+
+	return input, nil, nil
+}
+
+// newRouteConflictError returns the `*RouteError` of a "pattern"
+// which resolves to the "existing" registered node.
+func newRouteConflictError(pattern string, existing *Node, input []string) error {
+	err := ErrRouteExists
+	if !equalParamKeys(existing.paramKeys, patternParamKeys(input)) {
+		err = ErrParamNameConflict
+	}
+
+	return &RouteError{Pattern: pattern, Existing: existing.key, Err: err}
+}
+
+// searchPattern returns the node which the path pattern's segments resolve to, if any.
+func (t *Trie) searchPattern(input []string) *Node {
+	n := t.root
+	for _, s := range input {
+		if n = n.getPatternChild(s); n == nil {
+			return nil
+		}
+	}
+
+	return n
+}
+
+func patternParamKeys(input []string) (paramKeys []string) {
+	for _, s := range input {
+		if s == "" {
+			continue
+		}
+
+		switch s[0] {
+		case ParamStart[0]:
+			paramKey, _ := splitParamConstraint(s[1:])
+			paramKeys = append(paramKeys, paramKey)
+		case WildcardParamStart[0]:
+			paramKeys = append(paramKeys, s[1:])
+		}
+	}
+
+	return
+}
+
+func equalParamKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
 }
 
 const (
@@ -98,86 +299,243 @@ func slowPathSplit(path string) []string {
 		return []string{pathSep}
 	}
 
-	// remove last sep if any.
-	if path[len(path)-1] == pathSepB {
-		path = path[:len(path)-1]
-	}
-
+	// a trailing slash is kept as an empty last segment, so "/docs/" and "/docs" are different paths.
 	return strings.Split(path, pathSep)[1:]
 }
 
-func resolveStaticPart(key string) string {
-	i := strings.Index(key, ParamStart)
-	if i == -1 {
-		i = strings.Index(key, WildcardParamStart)
+// convertPattern converts the net/http ServeMux (Go 1.22+) wildcards of a path pattern
+// to the muxie ones, i.e "/files/{id}/{path...}" to "/files/:id/*path" and "/docs/{$}" to "/docs/".
+func convertPattern(pattern string) (string, error) {
+	if strings.IndexByte(pattern, '{') == -1 {
+		return pattern, nil
 	}
-	if i == -1 {
-		i = len(key)
+
+	segments := strings.Split(pattern, pathSep)
+	for i, s := range segments {
+		if s == "" || s[0] == ParamStart[0] { // braces of a constraint, i.e ":id|regex(^[0-9]{3}$)", are not touched.
+			continue
+		}
+
+		if s[0] != '{' || s[len(s)-1] != '}' {
+			if strings.IndexByte(s, '{') == -1 {
+				continue
+			}
+
+			return "", fmt.Errorf("bad wildcard segment %q: must be the whole path segment", s)
+		}
+
+		name := s[1 : len(s)-1]
+		switch {
+		case name == "$":
+			if i != len(segments)-1 {
+				return "", fmt.Errorf("{$} must be the last path segment")
+			}
+			segments[i] = ""
+		case strings.HasSuffix(name, "..."):
+			segments[i] = WildcardParamStart + name[:len(name)-3]
+		default:
+			segments[i] = ParamStart + name
+		}
+
+		if segments[i] == ParamStart || segments[i] == WildcardParamStart {
+			return "", fmt.Errorf("empty wildcard name in %q", s)
+		}
 	}
 
-	return key[:i]
+	return strings.Join(segments, pathSep), nil
 }
 
 func (t *Trie) insert(key, tag string, optionalData interface{}, handler http.Handler) *Node {
-	input := slowPathSplit(key)
+	n := t.insertNode(key)
+
+	n.Tag = tag
+	n.Handler = handler
+	n.main = nil
+	n.methods = nil
+	n.Data = optionalData
+
+	return n
+}
+
+// insertNode creates the missing nodes of the "key" path pattern's segments
+// and marks the last one as a final path, its data are left untouched.
+func (t *Trie) insertNode(key string) *Node {
+	pattern, err := convertPattern(key)
+	if err != nil {
+		panic(fmt.Sprintf("muxie/trie#Insert: %s: %v", key, err))
+	}
+
+	input := slowPathSplit(pattern)
 
 	n := t.root
-	if key == pathSep {
+	if pattern == pathSep {
 		t.hasRootSlash = true
 	}
 
 	var paramKeys []string
 
 	for _, s := range input {
-		c := s[0]
+		var c byte
+		if s != "" {
+			c = s[0]
+		}
 
 		if isParam, isWildcard := c == ParamStart[0], c == WildcardParamStart[0]; isParam || isWildcard {
-			n.hasDynamicChild = true
-			paramKeys = append(paramKeys, s[1:]) // without : or *.
+			paramKey := s[1:] // without : or *.
 
-			// if node has already a wildcard, don't force a value, check for true only.
 			if isParam {
-				n.childNamedParameter = true
-				s = ParamStart
+				// each different constraint lives on its own named parameter branch,
+				// so /users/:id|int and /users/:name/profile do not collide.
+				paramKey, constraintExpr := splitParamConstraint(paramKey)
+				paramKeys = append(paramKeys, paramKey)
+
+				child := n.getParamChild(constraintExpr)
+				if child == nil {
+					child = NewNode()
+					child.segment = ParamStart
+					if constraintExpr != "" {
+						constraint, err := parseParamConstraint(constraintExpr)
+						if err != nil {
+							panic(fmt.Sprintf("muxie/trie#Insert: %s: %v", key, err))
+						}
+
+						child.paramConstraint = constraint
+						child.paramConstraintExpr = constraintExpr
+					}
+					n.addParamChild(child)
+				}
+
+				n = child
+				continue
 			}
 
-			if isWildcard {
-				n.childWildcardParameter = true
-				s = WildcardParamStart
-				if t.root == n {
-					t.hasRootWildcard = true
-				}
+			paramKeys = append(paramKeys, paramKey)
+
+			// if node has already a wildcard, don't force a value, check for true only.
+			n.childWildcardParameter = true
+			s = WildcardParamStart
+			if t.root == n {
+				t.hasRootWildcard = true
 			}
 		}
 
 		if !n.hasChild(s) {
 			child := NewNode()
+			child.segment = s
 			n.addChild(s, child)
 		}
 
 		n = n.getChild(s)
 	}
 
-	n.Tag = tag
-	n.Handler = handler
-	n.Data = optionalData
-
 	n.paramKeys = paramKeys
 	n.key = key
-	n.staticKey = resolveStaticPart(key)
 	n.end = true
 
 	return n
 }
 
+// Delete removes the node which the path "pattern" resolves to,
+// prunes its no longer needed parent nodes and
+// recomputes their wildcard flags.
+// It reports whether a registered node was found and removed.
+//
+// Note that the `Trie` is not safe for concurrent use,
+// to remove routes of a serving `Mux` delete them from a `Clone`
+// and use the `Mux#Swap` instead.
+func (t *Trie) Delete(pattern string) bool {
+	pattern, err := convertPattern(pattern)
+	if err != nil || pattern == "" {
+		return false
+	}
+
+	n := t.searchPattern(slowPathSplit(pattern))
+	if n == nil || !n.end {
+		return false
+	}
+
+	if pattern == pathSep {
+		t.hasRootSlash = false
+	}
+
+	n.end = false
+	n.key = ""
+	n.paramKeys = nil
+	n.Handler = nil
+	n.Tag = ""
+	n.Data = nil
+
+	for n != t.root && !n.end && len(n.children) == 0 && len(n.paramChildren) == 0 {
+		parent := n.parent
+		parent.removeChild(n)
+		if parent == t.root {
+			t.hasRootWildcard = parent.childWildcardParameter
+		}
+		n = parent
+	}
+
+	return true
+}
+
+// Clone returns a deep copy of the Trie, the nodes' `Handler` and `Data` are shared.
+// Useful to modify the routes of a serving `Mux` and replace them with the `Mux#Swap`.
+func (t *Trie) Clone() *Trie {
+	return &Trie{
+		root:            t.root.clone(nil),
+		hasRootWildcard: t.hasRootWildcard,
+		hasRootSlash:    t.hasRootSlash,
+	}
+}
+
+// BuildPath returns the path of the node registered with the "tag", see `WithTag`,
+// by replacing its named parameters and wildcard with the escaped "params" values.
+// It returns an error if the tag is not registered, a parameter's value is missing
+// or a named parameter's value does not pass its constraint, if any.
+// When more than one node has the tag, i.e registered through `Insert`, the first one in the `Walk` order is used,
+// the `TryInsert` rejects a duplicate tag with the `ErrTagExists` instead.
+func (t *Trie) BuildPath(tag string, params map[string]string) (string, error) {
+	n := t.root.findTag(tag)
+	if n == nil {
+		return "", fmt.Errorf("muxie: route with tag %q not found", tag)
+	}
+
+	if n.key == pathSep {
+		return pathSep, nil
+	}
+
+	var b strings.Builder
+	if _, err := n.buildPath(&b, n.paramKeys, params); err != nil {
+		return "", fmt.Errorf("muxie: %s: %v", n.key, err)
+	}
+
+	return b.String(), nil
+}
+
+// Walk calls the "fn" for each registered node, the static paths are visited sorted,
+// then the named parameters and then the wildcards, see `Node#Key`, `Node#ParamKeys`, `Node#Tag` and `Node#Data`.
+// If "fn" returns a non-nil error then Walk stops and returns that error.
+func (t *Trie) Walk(fn func(*Node) error) error {
+	return t.root.walk(fn)
+}
+
 // SearchPrefix returns the last node which holds the key which starts with "prefix".
+// A trailing slash of the "prefix" is ignored.
 func (t *Trie) SearchPrefix(prefix string) *Node {
+	prefix, err := convertPattern(prefix)
+	if err != nil || prefix == "" {
+		return nil
+	}
+
+	if len(prefix) > 1 {
+		prefix = strings.TrimSuffix(prefix, pathSep)
+	}
+
 	input := slowPathSplit(prefix)
 	n := t.root
 
 	for i := 0; i < len(input); i++ {
 		s := input[i]
-		if child := n.getChild(s); child != nil {
+		if child := n.getPatternChild(s); child != nil {
 			n = child
 			continue
 		}
@@ -238,11 +596,29 @@ type ParamsSetter interface {
 // named parameters or wildcards.
 // Priority as:
 // 1. static paths
-// 2. named parameters with ":"
+// 2. named parameters with ":", if the path segment passes their constraint, if any (i.e ":id|int"),
+// the constrained ones first and then the unconstrained one
 // 3. wildcards
 // 4. closest wildcard if not found, if any
 // 5. root wildcard
+//
+// When a branch dead-ends on a next path segment then Search goes back and tries the next candidate
+// of the above list, so structurally different routes can live under the same path prefix.
 func (t *Trie) Search(q string, params ParamsSetter) *Node {
+	return t.search(q, params, false)
+}
+
+// SearchFold is like `Search` but it compares the static path segments case-insensitively,
+// i.e the "/About/Kataras" matches the "/about/:name" pattern with the "name" parameter value of "Kataras".
+// On each path segment an exact static match is preferred, then a case-insensitive static match,
+// then the named parameters and the wildcard. When two static path segments differ only by case,
+// the first registered one is tried.
+// See `Node#CanonicalPath` to get the path as it is registered.
+func (t *Trie) SearchFold(q string, params ParamsSetter) *Node {
+	return t.search(q, params, true)
+}
+
+func (t *Trie) search(q string, params ParamsSetter, fold bool) *Node {
 	end := len(q)
 
 	if end == 0 || (end == 1 && q[0] == pathSepB) {
@@ -257,94 +633,13 @@ func (t *Trie) Search(q string, params ParamsSetter) *Node {
 		return nil
 	}
 
-	n := t.root
-	start := 1
-	i := 1
-	var paramValues []string
-
-	for {
-		if i == end || q[i] == pathSepB {
-			if child := n.getChild(q[start:i]); child != nil {
-				n = child
-			} else if n.childNamedParameter { // && n.childWildcardParameter == false {
-				n = n.getChild(ParamStart)
-				if ln := len(paramValues); cap(paramValues) > ln {
-					paramValues = paramValues[:ln+1]
-					paramValues[ln] = q[start:i]
-				} else {
-					paramValues = append(paramValues, q[start:i])
-				}
-			} else if n.childWildcardParameter {
-				n = n.getChild(WildcardParamStart)
-				if ln := len(paramValues); cap(paramValues) > ln {
-					paramValues = paramValues[:ln+1]
-					paramValues[ln] = q[start:]
-				} else {
-					paramValues = append(paramValues, q[start:])
-				}
-				break
-			} else {
-				n = n.findClosestParentWildcardNode()
-				if n != nil {
-					// means that it has :param/static and *wildcard, we go trhough the :param
-					// but the next path segment is not the /static, so go back to *wildcard
-					// instead of not found.
-					//
-					// Fixes:
-					// /hello/*p
-					// /hello/:p1/static/:p2
-					// req: http://localhost:8080/hello/dsadsa/static/dsadsa => found
-					// req: http://localhost:8080/hello/dsadsa => but not found!
-					// and
-					// /second/wild/*p
-					// /second/wild/static/otherstatic/
-					// req: /second/wild/static/otherstatic/random => but not found!
-					params.Set(n.paramKeys[0], q[len(n.staticKey):])
-					return n
-				}
-
-				return nil
-			}
-
-			if i == end {
-				break
-			}
-
-			i++
-			start = i
-			continue
-		}
-
-		i++
-	}
-
-	if n == nil || !n.end {
-		if n != nil { // we need it on both places, on last segment (below) or on the first unnknown (above).
-			if n = n.findClosestParentWildcardNode(); n != nil {
-				params.Set(n.paramKeys[0], q[len(n.staticKey):])
-				return n
-			}
-		}
-
-		if t.hasRootWildcard {
-			// that's the case for root wildcard, tests are passing
-			// even without it but stick with it for reference.
-			// Note ote that something like:
-			// Routes: /other2/*myparam and /other2/static
-			// Reqs: /other2/staticed will be handled
-			// by the /other2/*myparam and not the root wildcard (see above), which is what we want.
-			n = t.root.getChild(WildcardParamStart)
-			params.Set(n.paramKeys[0], q[1:])
-			return n
-		}
-
+	n := t.root.search(q, 1, fold)
+	if n == nil {
 		return nil
 	}
 
-	for i, paramValue := range paramValues {
-		if len(n.paramKeys) > i {
-			params.Set(n.paramKeys[i], paramValue)
-		}
+	if len(n.paramKeys) > 0 {
+		n.setParams(q, n.paramKeys, params)
 	}
 
 	return n
@@ -1,6 +1,8 @@
 package muxie
 
 import (
+	"errors"
+	"net/http"
 	"strings"
 	"testing"
 )
@@ -190,3 +192,435 @@ func TestTrie(t *testing.T) {
 	t.Logf("Test node one by one\n")
 	testTrie(t, true)
 }
+
+func TestTrieCompetingNamedParameters(t *testing.T) {
+	tree := NewTrie()
+	tree.Insert("/users/:id|int", WithTag("user_by_id"))
+	tree.Insert("/users/:name/profile", WithTag("user_profile"))
+	tree.Insert("/users/new/edit", WithTag("new_user_edit"))
+	tree.Insert("/users/:id|int/friends/:friend|alpha", WithTag("user_friend_by_name"))
+	tree.Insert("/users/:id|int/friends/:friend", WithTag("user_friend"))
+	tree.Insert("/items/:id|int/*rest", WithTag("item_wildcard"))
+	tree.Insert("/items/:name", WithTag("item_by_name"))
+
+	tests := []struct {
+		path        string
+		expectedTag string
+		params      map[string]string
+	}{
+		{"/users/42", "user_by_id", map[string]string{"id": "42"}},
+		{"/users/kataras/profile", "user_profile", map[string]string{"name": "kataras"}},
+		// goes back from the ":id|int" branch to the ":name" one.
+		{"/users/42/profile", "user_profile", map[string]string{"name": "42"}},
+		// goes back from the "new" static branch to the ":name" one.
+		{"/users/new/profile", "user_profile", map[string]string{"name": "new"}},
+		{"/users/new/edit", "new_user_edit", nil},
+		{"/users/42/friends/makis", "user_friend_by_name", map[string]string{"id": "42", "friend": "makis"}},
+		{"/users/42/friends/7", "user_friend", map[string]string{"id": "42", "friend": "7"}},
+		{"/items/42/a/b", "item_wildcard", map[string]string{"id": "42", "rest": "a/b"}},
+		{"/items/42", "item_by_name", map[string]string{"name": "42"}},
+		{"/users/kataras", "", nil},
+		{"/users/kataras/friends/makis", "", nil},
+	}
+
+	for i, tt := range tests {
+		params := new(Writer)
+		n := tree.Search(tt.path, params)
+		if tt.expectedTag == "" {
+			if n != nil {
+				t.Fatalf("[%d] %s: expected to not be found but got: '%s'", i, tt.path, n.String())
+			}
+			continue
+		}
+
+		if n == nil {
+			t.Fatalf("[%d] %s: expected node with tag: '%s' to be found", i, tt.path, tt.expectedTag)
+		}
+
+		if expected, got := tt.expectedTag, n.Tag; expected != got {
+			t.Fatalf("[%d] %s: expected tag: '%s' but got: '%s'", i, tt.path, expected, got)
+		}
+
+		if expected, got := len(tt.params), len(params.GetAll()); expected != got {
+			t.Fatalf("[%d] %s: expected %d params but got: %d", i, tt.path, expected, got)
+		}
+
+		for key, expectedValue := range tt.params {
+			if got := params.Get(key); expectedValue != got {
+				t.Fatalf("[%d] %s: expected param '%s' to be: '%s' but got: '%s'", i, tt.path, key, expectedValue, got)
+			}
+		}
+	}
+}
+
+func TestTrieTryInsert(t *testing.T) {
+	tree := NewTrie()
+	for _, pattern := range []string{"/", "/a", "/a/:x", "/a/:x|int", "/a/:x/b", "/a/*w"} {
+		if err := tree.TryInsert(pattern); err != nil {
+			t.Fatalf("%s: expected no error but got: %v", pattern, err)
+		}
+	}
+
+	tests := []struct {
+		pattern     string
+		expectedErr error
+		existing    string
+	}{
+		{"/", ErrRouteExists, "/"},
+		{"/a", ErrRouteExists, "/a"},
+		{"/a/:x", ErrRouteExists, "/a/:x"},
+		{"/a/:y", ErrParamNameConflict, "/a/:x"},
+		{"/a/:y|int", ErrParamNameConflict, "/a/:x|int"},
+		{"/a/*other", ErrParamNameConflict, "/a/*w"},
+		{"/b/*w/c", ErrWildcardNotLast, ""},
+		{"/b//c", ErrEmptySegment, ""},
+	}
+
+	for i, tt := range tests {
+		err := tree.TryInsert(tt.pattern, WithTag("should not be set"))
+		if err == nil {
+			t.Fatalf("[%d] %s: expected error", i, tt.pattern)
+		}
+
+		if !errors.Is(err, tt.expectedErr) {
+			t.Fatalf("[%d] %s: expected error: '%v' but got: '%v'", i, tt.pattern, tt.expectedErr, err)
+		}
+
+		if expected, got := tt.existing, err.(*RouteError).Existing; expected != got {
+			t.Fatalf("[%d] %s: expected existing route: '%s' but got: '%s'", i, tt.pattern, expected, got)
+		}
+	}
+
+	if err := tree.TryInsert("/a/:x|unknown"); err == nil {
+		t.Fatalf("expected error for unknown parameter constraint")
+	}
+
+	if n := tree.Search("/a/42", new(Writer)); n == nil || n.Tag != "" {
+		t.Fatalf("expected the first registered route to be kept")
+	}
+}
+
+func TestTrieDelete(t *testing.T) {
+	tree := NewTrie()
+	tree.Insert("/", WithTag("root"))
+	tree.Insert("/*anything", WithTag("root_wildcard"))
+	tree.Insert("/users/:id|int", WithTag("user_by_id"))
+	tree.Insert("/users/:name/profile", WithTag("user_profile"))
+	tree.Insert("/users/*path", WithTag("users_wildcard"))
+
+	expectTag := func(path, expectedTag string) {
+		t.Helper()
+
+		n := tree.Search(path, new(Writer))
+		if expectedTag == "" {
+			if n != nil {
+				t.Fatalf("%s: expected to not be found but got: '%s'", path, n.String())
+			}
+			return
+		}
+
+		if n == nil {
+			t.Fatalf("%s: expected node with tag: '%s' to be found", path, expectedTag)
+		}
+
+		if expected, got := expectedTag, n.Tag; expected != got {
+			t.Fatalf("%s: expected tag: '%s' but got: '%s'", path, expected, got)
+		}
+	}
+
+	if tree.Delete("/users/:id|int/unknown") || tree.Delete("/users") {
+		t.Fatalf("expected unregistered patterns to not be deleted")
+	}
+
+	if !tree.Delete("/users/:name/profile") {
+		t.Fatalf("expected pattern to be deleted")
+	}
+	expectTag("/users/kataras/profile", "users_wildcard")
+	expectTag("/users/42", "user_by_id")
+
+	tree.Delete("/users/*path")
+	expectTag("/users/kataras/profile", "root_wildcard")
+	if n := tree.SearchPrefix("/users"); n == nil || n.childWildcardParameter || n.getParamChild("") != nil {
+		t.Fatalf("expected the users node to be kept without wildcard and unconstrained named parameter children")
+	}
+
+	tree.Delete("/users/:id|int")
+	if tree.HasPrefix("/users") {
+		t.Fatalf("expected the users node to be pruned")
+	}
+
+	tree.Delete("/*anything")
+	expectTag("/users/42", "")
+	expectTag("/", "root")
+
+	tree.Delete("/")
+	expectTag("/", "")
+}
+
+func TestTrieClone(t *testing.T) {
+	tree := NewTrie()
+	tree.Insert("/users/:id", WithTag("user"))
+
+	clone := tree.Clone()
+	clone.Delete("/users/:id")
+	clone.Insert("/about", WithTag("about"))
+
+	if n := tree.Search("/users/42", new(Writer)); n == nil || n.Tag != "user" {
+		t.Fatalf("expected the original trie to be untouched")
+	}
+
+	if tree.HasPrefix("/about") {
+		t.Fatalf("expected the original trie to be untouched")
+	}
+
+	if n := clone.Search("/about", new(Writer)); n == nil || n.Tag != "about" {
+		t.Fatalf("expected the clone to contain the new node")
+	}
+}
+
+func TestTrieBuildPath(t *testing.T) {
+	tree := NewTrie()
+	tree.Insert("/", WithTag("root"))
+	tree.Insert("/about", WithTag("about"))
+	tree.Insert("/users/:id|int", WithTag("user"))
+	tree.Insert("/users/:name/files/*file", WithTag("user_file"))
+
+	tests := []struct {
+		tag          string
+		params       map[string]string
+		expectedPath string
+		expectErr    bool
+	}{
+		{"root", nil, "/", false},
+		{"about", nil, "/about", false},
+		{"user", map[string]string{"id": "42"}, "/users/42", false},
+		{"user", map[string]string{"id": "kataras"}, "", true},
+		{"user", nil, "", true},
+		{"user_file", map[string]string{"name": "a b/c", "file": "docs/my file.pdf"}, "/users/a%20b%2Fc/files/docs/my%20file.pdf", false},
+		{"user_file", map[string]string{"name": "kataras"}, "", true},
+		{"unknown", nil, "", true},
+	}
+
+	for i, tt := range tests {
+		path, err := tree.BuildPath(tt.tag, tt.params)
+		if tt.expectErr {
+			if err == nil {
+				t.Fatalf("[%d] %s: expected error but got path: '%s'", i, tt.tag, path)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("[%d] %s: %v", i, tt.tag, err)
+		}
+
+		if expected, got := tt.expectedPath, path; expected != got {
+			t.Fatalf("[%d] %s: expected path: '%s' but got: '%s'", i, tt.tag, expected, got)
+		}
+	}
+}
+
+func TestTrieDuplicateTag(t *testing.T) {
+	// the first node in the walk order wins, on every run.
+	for i := 0; i < 20; i++ {
+		tree := NewTrie()
+		tree.Insert("/users/:id", WithTag("user"))
+		tree.Insert("/b", WithTag("user"))
+		tree.Insert("/a", WithTag("user"))
+		tree.Insert("/c/d", WithTag("user"))
+
+		if path, err := tree.BuildPath("user", map[string]string{"id": "42"}); err != nil || path != "/a" {
+			t.Fatalf("[%d] expected path: '/a' but got: '%s' (%v)", i, path, err)
+		}
+	}
+
+	tree := NewTrie()
+	if err := tree.TryInsert("/a", WithTag("user")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := tree.TryInsert("/b", WithTag("user"))
+	var routeErr *RouteError
+	if !errors.As(err, &routeErr) || !errors.Is(err, ErrTagExists) || routeErr.Existing != "/a" {
+		t.Fatalf("expected a tag exists error but got: %v", err)
+	}
+
+	if tree.HasPrefix("/b") {
+		t.Fatalf("expected the trie to be left untouched")
+	}
+}
+
+func TestTrieServeMuxPatterns(t *testing.T) {
+	tree := NewTrie()
+	tree.Insert("/items/{id|int}", WithTag("item"))
+	tree.Insert("/files/{name}/{path...}", WithTag("file"))
+	tree.Insert("/{$}", WithTag("root"))
+
+	tests := []struct {
+		path        string
+		expectedTag string
+		params      map[string]string
+	}{
+		{"/items/42", "item", map[string]string{"id": "42"}},
+		{"/files/kataras/docs/a.pdf", "file", map[string]string{"name": "kataras", "path": "docs/a.pdf"}},
+		{"/", "root", nil},
+	}
+
+	for i, tt := range tests {
+		params := new(Writer)
+		n := tree.Search(tt.path, params)
+		if n == nil || n.Tag != tt.expectedTag {
+			t.Fatalf("[%d] %s: expected node with tag: '%s' to be found", i, tt.path, tt.expectedTag)
+		}
+
+		for key, expectedValue := range tt.params {
+			if got := params.Get(key); expectedValue != got {
+				t.Fatalf("[%d] %s: expected param '%s' to be: '%s' but got: '%s'", i, tt.path, key, expectedValue, got)
+			}
+		}
+	}
+
+	if n := tree.Search("/items/42", new(Writer)); n.Key() != "/items/{id|int}" {
+		t.Fatalf("expected the original pattern to be kept as the node's key but got: '%s'", n.Key())
+	}
+
+	if err := tree.TryInsert("/items/:id|int"); !errors.Is(err, ErrRouteExists) {
+		t.Fatalf("expected route exists error but got: %v", err)
+	}
+
+	if !tree.Delete("/files/{name}/{path...}") || tree.HasPrefix("/files") {
+		t.Fatalf("expected the files pattern to be deleted")
+	}
+
+	for _, pattern := range []string{"/items/{id", "/items/x{id}", "/items/{}", "/items/{...}", "/{$}/a", "/{path...}/a"} {
+		if err := tree.TryInsert(pattern); err == nil {
+			t.Fatalf("%s: expected error", pattern)
+		}
+	}
+}
+
+func TestTrieSearchFold(t *testing.T) {
+	tree := NewTrie()
+	tree.Insert("/about/:name", WithTag("about"))
+	tree.Insert("/About/us", WithTag("about_us"))
+	tree.Insert("/Docs/*file", WithTag("docs"))
+
+	tests := []struct {
+		path        string
+		expectedTag string
+		canonical   string
+		paramKey    string
+		paramValue  string
+	}{
+		{"/ABOUT/Kataras", "about", "/about/Kataras", "name", "Kataras"},
+		{"/about/us", "about", "/about/us", "name", "us"},
+		{"/about/US", "about", "/about/US", "name", "US"},
+		{"/About/us", "about_us", "/About/us", "", ""},
+		{"/docs/Intro/Setup.md", "docs", "/Docs/Intro/Setup.md", "file", "Intro/Setup.md"},
+	}
+
+	for i, tt := range tests {
+		params := new(Writer)
+		n := tree.SearchFold(tt.path, params)
+		if n == nil {
+			t.Fatalf("[%d] %s: expected node with tag: '%s' to be found", i, tt.path, tt.expectedTag)
+		}
+
+		if expected, got := tt.expectedTag, n.Tag; expected != got {
+			t.Fatalf("[%d] %s: expected tag: '%s' but got: '%s'", i, tt.path, expected, got)
+		}
+
+		if expected, got := tt.canonical, n.CanonicalPath(tt.path); expected != got {
+			t.Fatalf("[%d] %s: expected canonical path: '%s' but got: '%s'", i, tt.path, expected, got)
+		}
+
+		if tt.paramKey != "" {
+			if expected, got := tt.paramValue, params.Get(tt.paramKey); expected != got {
+				t.Fatalf("[%d] %s: expected param '%s' to be: '%s' but got: '%s'", i, tt.path, tt.paramKey, expected, got)
+			}
+		}
+	}
+
+	if n := tree.Search("/ABOUT/Kataras", new(Writer)); n != nil {
+		t.Fatalf("expected the case-sensitive search to not match")
+	}
+
+	// the remaining static segment of the same case folding takes over.
+	tree.Insert("/ABOUT/team", WithTag("about_team"))
+	tree.Delete("/about/:name")
+	clone := tree.Clone()
+	for _, tr := range []*Trie{tree, clone} {
+		if n := tr.SearchFold("/about/team", new(Writer)); n == nil || n.Tag != "about_team" {
+			t.Fatalf("expected the '/ABOUT/team' to be found")
+		}
+	}
+}
+
+func TestTrieTrailingSlash(t *testing.T) {
+	tree := NewTrie()
+	tree.Insert("/docs", WithTag("docs"))
+	tree.Insert("/docs/", WithTag("docs_slash"))
+	tree.Insert("/users/:id/", WithTag("user_slash"))
+
+	tests := []struct {
+		path        string
+		expectedTag string
+	}{
+		{"/docs", "docs"},
+		{"/docs/", "docs_slash"},
+		{"/users/42/", "user_slash"},
+		{"/users/42", ""},
+	}
+
+	for i, tt := range tests {
+		n := tree.Search(tt.path, new(Writer))
+		if tt.expectedTag == "" {
+			if n != nil {
+				t.Fatalf("[%d] %s: expected to not be found but got: '%s'", i, tt.path, n.Tag)
+			}
+			continue
+		}
+
+		if n == nil || n.Tag != tt.expectedTag {
+			t.Fatalf("[%d] %s: expected node with tag: '%s' to be found", i, tt.path, tt.expectedTag)
+		}
+	}
+
+	if err := tree.TryInsert("/docs/"); !errors.Is(err, ErrRouteExists) {
+		t.Fatalf("expected route exists error but got: %v", err)
+	}
+
+	if err := tree.TryInsert("/a//"); !errors.Is(err, ErrEmptySegment) {
+		t.Fatalf("expected empty segment error but got: %v", err)
+	}
+
+	if path, err := tree.BuildPath("user_slash", map[string]string{"id": "42"}); err != nil || path != "/users/42/" {
+		t.Fatalf("expected path: '/users/42/' but got: '%s' (%v)", path, err)
+	}
+
+	if !tree.Delete("/docs/") || tree.Search("/docs/", new(Writer)) != nil || tree.Search("/docs", new(Writer)) == nil {
+		t.Fatalf("expected only the '/docs/' to be deleted")
+	}
+}
+
+func TestTrieWith(t *testing.T) {
+	var order []string
+	middleware := func(name string) Wrapper {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	tree := NewTrie()
+	tree.Insert("/admin", WithHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "main")
+	})), With(middleware("first")), With(middleware("second")))
+
+	tree.Search("/admin", new(Writer)).Handler.ServeHTTP(nil, nil)
+	if expected, got := "first,second,main", strings.Join(order, ","); expected != got {
+		t.Fatalf("expected order: '%s' but got: '%s'", expected, got)
+	}
+}
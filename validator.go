@@ -0,0 +1,355 @@
+package muxie
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes a struct field which does not pass a validation rule, see `Validate`.
+type FieldError struct {
+	// Field is the path of the field, i.e "address.city" or "items[1].name".
+	Field string `json:"field"`
+	// Rule is the failed rule, i.e "min".
+	Rule string `json:"rule"`
+	// Param is the parameter of the rule, if any, i.e "3".
+	Param string `json:"param,omitempty"`
+	// Message is a human-readable explanation of the failure, i.e "must be at least 3 characters long".
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors is the list of the `FieldError`s that `Validate` returns.
+// It can be sent to the client through `WriteError`, as 422 `Problem` with the fields as its Errors.
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+
+	return "muxie: validation failed: " + strings.Join(messages, "; ")
+}
+
+// Problem returns the 422 `Problem` of the errors, see `WriteError`.
+func (errs ValidationErrors) Problem() *Problem {
+	p := NewProblem(http.StatusUnprocessableEntity, "validation failed")
+	p.Errors = errs
+	return p
+}
+
+// Validated returns a `Binder` which validates the struct value (ptr)
+// after the "b" Binder binds the request to it, see `Validate`, i.e:
+//
+//	if err := muxie.Bind(r, muxie.Validated(muxie.JSON), &user); err != nil {
+//	    muxie.WriteError(w, err)
+//	    return
+//	}
+func Validated(b Binder) Binder {
+	return &validatedBinder{b}
+}
+
+type validatedBinder struct {
+	Binder
+}
+
+func (p *validatedBinder) bindsBody() bool { return bindsBody(p.Binder) }
+
+func (p *validatedBinder) Bind(r *http.Request, v interface{}) error {
+	if err := p.Binder.Bind(r, v); err != nil {
+		return err
+	}
+
+	return Validate(v)
+}
+
+// Validate validates the fields of a struct value (or a pointer to it) based on their `validate` struct tags,
+// i.e `validate:"required,min=3,max=64"`. The available rules are:
+//
+//	required  the value is not the zero value, i.e not empty string or nil
+//	omitempty the rest of the rules are skipped when the value is the zero value
+//	min=n     the number, the string length (characters) or the slice length (items) is at least n
+//	max=n     like the min but at most n
+//	len=n     the string (characters) or the slice (items) length is exactly n
+//	email     the string is an email address, i.e "name@example.com"
+//	url       the string is an absolute URL, i.e "https://example.com"
+//	uuid      the string is a UUID
+//	oneof=a b the string or the number is one of the space-separated values
+//
+// The rules of a nil pointer are skipped, except the required one.
+// The nested structs and the slices of structs are validated too.
+//
+// It returns the `ValidationErrors` of the fields, the field paths are
+// based on their `json`, `form`, `query` or `path` struct tags, or their names.
+// An unknown rule is reported as a plain error.
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("muxie: validate: expected a struct but got %T", v)
+	}
+
+	var errs ValidationErrors
+	if err := validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func validateStruct(v reflect.Value, path string, errs *ValidationErrors) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+
+		fieldPath := path
+		// the fields of an embedded struct are reported as they were fields of its parent.
+		if name := validationFieldName(field); !field.Anonymous || name != field.Name {
+			fieldPath = joinFieldPath(path, name)
+		}
+
+		fv := v.Field(i)
+		if tag != "" {
+			if err := validateField(fv, fieldPath, tag, errs); err != nil {
+				return err
+			}
+		}
+
+		if err := validateNested(fv, fieldPath, errs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateNested validates the struct values of a field, if any.
+func validateNested(v reflect.Value, path string, errs *ValidationErrors) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return nil
+		}
+
+		return validateStruct(v, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateNested(v.Index(i), path+"["+strconv.Itoa(i)+"]", errs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateField checks the rules of the "tag" against the "v" value,
+// the first failed rule of the field is added to the "errs".
+func validateField(v reflect.Value, path string, tag string, errs *ValidationErrors) error {
+	rules := strings.Split(tag, ",")
+
+	for _, rule := range rules {
+		if rule == "omitempty" && v.IsZero() {
+			return nil
+		}
+	}
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			for _, rule := range rules {
+				if rule == "required" {
+					*errs = append(*errs, FieldError{Field: path, Rule: rule, Message: "is required"})
+					break
+				}
+			}
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "" || name == "omitempty" {
+			continue
+		}
+
+		message, err := checkRule(v, name, param)
+		if err != nil {
+			return fmt.Errorf("muxie: validate: field %q: %w", path, err)
+		}
+
+		if message != "" {
+			*errs = append(*errs, FieldError{Field: path, Rule: name, Param: param, Message: message})
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// checkRule returns the failure message of the rule, empty if the "v" passes it.
+func checkRule(v reflect.Value, rule, param string) (string, error) {
+	switch rule {
+	case "required":
+		if v.IsZero() || (isSized(v) && v.Len() == 0) {
+			return "is required", nil
+		}
+	case "min", "max", "len":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return "", fmt.Errorf("rule %q: invalid parameter %q", rule, param)
+		}
+
+		size, unit, ok := valueSize(v)
+		if !ok || (rule == "len" && unit == "") {
+			return "", fmt.Errorf("rule %q is not supported by %s", rule, v.Type())
+		}
+
+		verb := "must be "
+		if unit == " items" {
+			verb = "must have "
+		}
+
+		switch {
+		case rule == "min" && size < limit:
+			return verb + "at least " + param + unit, nil
+		case rule == "max" && size > limit:
+			return verb + "at most " + param + unit, nil
+		case rule == "len" && size != limit:
+			return verb + "exactly " + param + unit, nil
+		}
+	case "email":
+		s, err := stringValue(v, rule)
+		if err != nil {
+			return "", err
+		}
+
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+			return "must be a valid email address", nil
+		}
+	case "url":
+		s, err := stringValue(v, rule)
+		if err != nil {
+			return "", err
+		}
+
+		if u, err := url.ParseRequestURI(s); err != nil || u.Scheme == "" || u.Host == "" {
+			return "must be a valid URL", nil
+		}
+	case "uuid":
+		s, err := stringValue(v, rule)
+		if err != nil {
+			return "", err
+		}
+
+		if !isUUID(s) {
+			return "must be a valid UUID", nil
+		}
+	case "oneof":
+		if _, unit, ok := valueSize(v); !ok || (unit != "" && v.Kind() != reflect.String) {
+			return "", fmt.Errorf("rule %q is not supported by %s", rule, v.Type())
+		}
+
+		value := fmt.Sprint(v.Interface())
+		options := strings.Fields(param)
+		for _, option := range options {
+			if option == value {
+				return "", nil
+			}
+		}
+
+		return "must be one of: " + strings.Join(options, ", "), nil
+	default:
+		return "", fmt.Errorf("unknown rule %q", rule)
+	}
+
+	return "", nil
+}
+
+func isSized(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return true
+	default:
+		return false
+	}
+}
+
+// valueSize returns the number of a numeric value or the length of a string (characters)
+// or a slice (items) value, and its unit.
+func valueSize(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters long", true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), " items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	default:
+		return 0, "", false
+	}
+}
+
+func stringValue(v reflect.Value, rule string) (string, error) {
+	if v.Kind() != reflect.String {
+		return "", fmt.Errorf("rule %q is not supported by %s", rule, v.Type())
+	}
+
+	return v.String(), nil
+}
+
+// validationFieldName returns the name of the field as the client sends it,
+// based on its `json`, `form`, `query` or `path` struct tags.
+func validationFieldName(field reflect.StructField) string {
+	for _, key := range [...]string{"json", "form", "query", "path"} {
+		if name, _ := parseFieldTag(field.Tag.Get(key)); name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return field.Name
+}
+
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
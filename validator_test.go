@@ -0,0 +1,136 @@
+package muxie
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	type item struct {
+		Name string `json:"name" validate:"required"`
+	}
+
+	type Audit struct {
+		By string `json:"by" validate:"omitempty,email"`
+	}
+
+	type order struct {
+		Audit
+		Customer string   `json:"customer" validate:"required,min=3,max=8"`
+		Email    string   `json:"email" validate:"email"`
+		Website  string   `json:"website" validate:"omitempty,url"`
+		ID       string   `json:"id" validate:"omitempty,uuid"`
+		Status   string   `json:"status" validate:"oneof=new paid"`
+		Quantity int      `json:"quantity" validate:"min=1,max=10"`
+		Tags     []string `json:"tags" validate:"max=2"`
+		Code     string   `json:"code" validate:"len=4"`
+		Note     *string  `json:"note" validate:"required"`
+		Items    []item   `json:"items"`
+		Skipped  string   `validate:"-"`
+	}
+
+	note := "fragile"
+	valid := order{
+		Customer: "kataras",
+		Email:    "kataras@example.com",
+		Website:  "https://example.com",
+		ID:       "9f5c1c3e-8e3e-4b3e-9c8e-3e8e4b3e9c8e",
+		Status:   "paid",
+		Quantity: 2,
+		Tags:     []string{"a"},
+		Code:     "αβγδ",
+		Note:     &note,
+		Items:    []item{{Name: "book"}},
+	}
+
+	if err := Validate(&valid); err != nil {
+		t.Fatalf("expected a valid order but got: %v", err)
+	}
+
+	invalid := order{
+		Audit:    Audit{By: "nobody"},
+		Customer: "ab",
+		Email:    "Kataras <kataras@example.com>",
+		Website:  "example.com",
+		Status:   "cancelled",
+		Quantity: 11,
+		Tags:     []string{"a", "b", "c"},
+		Code:     "abc",
+		Items:    []item{{Name: "book"}, {}},
+	}
+
+	err := Validate(invalid)
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validation errors but got: %v", err)
+	}
+
+	expected := ValidationErrors{
+		{Field: "by", Rule: "email", Message: "must be a valid email address"},
+		{Field: "customer", Rule: "min", Param: "3", Message: "must be at least 3 characters long"},
+		{Field: "email", Rule: "email", Message: "must be a valid email address"},
+		{Field: "website", Rule: "url", Message: "must be a valid URL"},
+		{Field: "status", Rule: "oneof", Param: "new paid", Message: "must be one of: new, paid"},
+		{Field: "quantity", Rule: "max", Param: "10", Message: "must be at most 10"},
+		{Field: "tags", Rule: "max", Param: "2", Message: "must have at most 2 items"},
+		{Field: "code", Rule: "len", Param: "4", Message: "must be exactly 4 characters long"},
+		{Field: "note", Rule: "required", Message: "is required"},
+		{Field: "items[1].name", Rule: "required", Message: "is required"},
+	}
+
+	if !reflect.DeepEqual(errs, expected) {
+		t.Fatalf("expected errors:\n%v\nbut got:\n%v", expected, errs)
+	}
+
+	if err = Validate(&struct {
+		Name string `validate:"required,unknown"`
+	}{Name: "x"}); err == nil || errors.As(err, &errs) {
+		t.Fatalf("expected a plain error for an unknown rule but got: %v", err)
+	}
+
+	if err = Validate("string"); err == nil {
+		t.Fatalf("expected an error for a non-struct value")
+	}
+}
+
+func TestValidated(t *testing.T) {
+	type user struct {
+		Name string `json:"name" validate:"required,min=3"`
+	}
+
+	mux := NewMux()
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		var u user
+		if err := Bind(r, Validated(JSON), &u); err != nil {
+			WriteError(w, err)
+			return
+		}
+
+		Dispatch(w, JSON, u)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	expectWithBody(t, http.MethodPost, srv.URL+"/users", `{"name":"kataras"}`, nil).
+		statusCode(http.StatusOK).bodyEq(`{"name":"kataras"}`)
+
+	te := expectWithBody(t, http.MethodPost, srv.URL+"/users", `{"name":"ab"}`, nil).
+		statusCode(http.StatusUnprocessableEntity).headerEq("Content-Type", ProblemContentType)
+
+	defer te.resp.Body.Close()
+
+	var p Problem
+	if err := json.NewDecoder(te.resp.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Errors) != 1 || p.Errors[0].Field != "name" || p.Errors[0].Rule != "min" {
+		t.Fatalf("unexpected problem errors: %#v", p.Errors)
+	}
+}